@@ -0,0 +1,201 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package balance computes running balances for each account and checks
+// them against the statement balances in the account list.
+package balance
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader/account"
+	"github.com/maloquacious/qif/stdlib"
+	"sort"
+)
+
+// Entry is a transaction and the balance of its account after it.
+type Entry struct {
+	Transaction *normalizer.Transaction
+	Balance     stdlib.Amount
+}
+
+// Register is the transactions of one account in date order.
+type Register struct {
+	Account string
+	Entries []*Entry
+}
+
+// Registers returns the running balance of each account. Transactions on
+// the same date stay in file order. Investment accounts are skipped since
+// their balance depends on the price of the securities.
+//
+// Registers are sorted by account name.
+func Registers(transactions []*normalizer.Transaction) []*Register {
+	accounts := make(map[string]*Register)
+	var registers []*Register
+	for _, t := range transactions {
+		if t.Type == "Invst" {
+			continue
+		}
+		register, ok := accounts[t.Account]
+		if !ok {
+			register = &Register{Account: t.Account}
+			accounts[t.Account] = register
+			registers = append(registers, register)
+		}
+		register.Entries = append(register.Entries, &Entry{Transaction: t})
+	}
+	for _, register := range registers {
+		sort.SliceStable(register.Entries, func(i, j int) bool {
+			return register.Entries[i].Transaction.Date.Before(register.Entries[j].Transaction.Date)
+		})
+		var balance stdlib.Amount
+		for _, e := range register.Entries {
+			balance = balance.Add(e.Transaction.Total)
+			e.Balance = balance
+		}
+	}
+	sort.Slice(registers, func(i, j int) bool {
+		return registers[i].Account < registers[j].Account
+	})
+	return registers
+}
+
+// Balance returns the balance at the end of the date.
+func (r *Register) Balance(date stdlib.CivilDate) stdlib.Amount {
+	var balance stdlib.Amount
+	for _, e := range r.Entries {
+		if e.Transaction.Date.After(date) {
+			break
+		}
+		balance = e.Balance
+	}
+	return balance
+}
+
+// Reconciliation compares the register with the statement balance of an
+// account. The statement only includes reconciled transactions, so the
+// outstanding transactions explain the difference between the register
+// and the statement.
+type Reconciliation struct {
+	Line        int // the line of the account
+	Account     string
+	Date        stdlib.CivilDate // the date of the statement
+	Statement   stdlib.Amount
+	Register    stdlib.Amount             // balance of every transaction through the date
+	Outstanding []*normalizer.Transaction // transactions through the date that haven't cleared
+	Total       stdlib.Amount             // total of the outstanding transactions
+}
+
+// Difference returns the register balance less the statement balance.
+func (r Reconciliation) Difference() stdlib.Amount {
+	return r.Register.Sub(r.Statement)
+}
+
+// Discrepancy returns the part of the difference that the outstanding
+// transactions don't explain.
+func (r Reconciliation) Discrepancy() stdlib.Amount {
+	return r.Difference().Sub(r.Total)
+}
+
+// IsBalanced returns true if the outstanding transactions explain the
+// difference.
+func (r Reconciliation) IsBalanced() bool {
+	return r.Discrepancy().IsZero()
+}
+
+func (r Reconciliation) String() string {
+	if r.IsBalanced() {
+		return fmt.Sprintf("%d: %s: %q: statement %s, register %s, %d outstanding for %s", r.Line, r.Date, r.Account, r.Statement, r.Register, len(r.Outstanding), r.Total)
+	}
+	return fmt.Sprintf("%d: %s: %q: statement %s, register %s, %d outstanding for %s, off by %s", r.Line, r.Date, r.Account, r.Statement, r.Register, len(r.Outstanding), r.Total, r.Discrepancy())
+}
+
+// Reconcile checks every account that has a statement balance and date.
+// It returns an error if a statement balance isn't a valid amount.
+//
+// Reconciliations are in the order of the account list.
+func Reconcile(accounts []*account.Record, registers []*Register) ([]Reconciliation, error) {
+	byName := make(map[string]*Register)
+	for _, register := range registers {
+		byName[register.Account] = register
+	}
+	var reconciliations []Reconciliation
+	for _, a := range accounts {
+		if a.StatementBalance == "" || a.StatementBalanceDate.IsZero() {
+			continue
+		}
+		statement, err := stdlib.ParseAmount(a.StatementBalance)
+		if err != nil {
+			return nil, fmt.Errorf("%d: account: %w", a.Line, err)
+		}
+		r := Reconciliation{Line: a.Line, Account: a.Name, Date: a.StatementBalanceDate, Statement: statement}
+		if register := byName[a.Name]; register != nil {
+			r.Register = register.Balance(r.Date)
+			for _, e := range register.Entries {
+				if e.Transaction.Date.After(r.Date) {
+					break
+				} else if e.Transaction.ClearedStatus != normalizer.Reconciled {
+					r.Outstanding = append(r.Outstanding, e.Transaction)
+					r.Total = r.Total.Add(e.Transaction.Total)
+				}
+			}
+		}
+		reconciliations = append(reconciliations, r)
+	}
+	return reconciliations, nil
+}
+
+// Summary is the total of each cleared status for an account.
+type Summary struct {
+	Account     string
+	Reconciled  stdlib.Amount
+	Cleared     stdlib.Amount // checked off but not reconciled
+	Outstanding stdlib.Amount
+}
+
+// Balance returns the total of every transaction.
+func (s Summary) Balance() stdlib.Amount {
+	return s.Reconciled.Add(s.Cleared).Add(s.Outstanding)
+}
+
+// Summarize returns the totals of each register by cleared status.
+func Summarize(registers []*Register) []Summary {
+	var summaries []Summary
+	for _, register := range registers {
+		s := Summary{Account: register.Account}
+		for _, e := range register.Entries {
+			switch e.Transaction.ClearedStatus {
+			case normalizer.Reconciled:
+				s.Reconciled = s.Reconciled.Add(e.Transaction.Total)
+			case normalizer.Cleared:
+				s.Cleared = s.Cleared.Add(e.Transaction.Total)
+			default:
+				s.Outstanding = s.Outstanding.Add(e.Transaction.Total)
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
@@ -0,0 +1,153 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package balance_test
+
+import (
+	"github.com/maloquacious/qif/balance"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"strings"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	// Specification: Statement reconciliation
+
+	// Given a checking account with a statement balance on 1/31 and a
+	// savings account whose statement doesn't match, with transactions
+	// out of date order
+	input := `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+$950.00
+/1/31'16
+^
+NSavings
+TBank
+$75.00
+/1/31'16
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 1'16
+T1,000.00
+CR
+POpening Balance
+L[Checking]
+^
+D1/20'16
+T-30.00
+PPharmacy
+LMedical
+^
+D1/10'16
+T-50.00
+CX
+PGrocer
+LGroceries
+^
+D2/ 2'16
+T-10.00
+PDiner
+LDining
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D1/ 1'16
+T100.00
+CX
+PDeposit
+LSalary
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the registers are computed
+	registers := balance.Registers(transactions)
+	if len(registers) != 2 || registers[0].Account != "Checking" {
+		t.Fatalf("registers: yields %d: expected 2\n", len(registers))
+	}
+
+	// Then the running balance is in date order
+	var balances []string
+	for _, e := range registers[0].Entries {
+		balances = append(balances, e.Balance.String())
+	}
+	if yields, expected := strings.Join(balances, " "), "1000.00 950.00 920.00 910.00"; yields != expected {
+		t.Errorf("balances: yields %q: expected %q\n", yields, expected)
+	}
+
+	// When they are reconciled against the statements
+	reconciliations, err := balance.Reconcile(r.Accounts.Records, registers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reconciliations) != 2 {
+		t.Fatalf("reconciliations: yields %d: expected 2\n", len(reconciliations))
+	}
+
+	// Then the uncleared transaction explains the difference in checking
+	checking := reconciliations[0]
+	if checking.Register.String() != "920.00" || checking.Difference().String() != "-30.00" || !checking.IsBalanced() {
+		t.Errorf("checking: yields %s: expected it to balance\n", checking)
+	}
+	if len(checking.Outstanding) != 1 || checking.Outstanding[0].Payee != "Pharmacy" {
+		t.Errorf("checking: yields %d outstanding: expected the pharmacy\n", len(checking.Outstanding))
+	}
+
+	// And the discrepancy in savings is reported
+	savings := reconciliations[1]
+	if savings.IsBalanced() || savings.Discrepancy().String() != "25.00" || len(savings.Outstanding) != 0 {
+		t.Errorf("savings: yields %s: expected to be off by 25.00\n", savings)
+	}
+
+	// When the registers are summarized by cleared status
+	summaries := balance.Summarize(registers)
+
+	// Then each status has its own total
+	if s := summaries[0]; s.Reconciled.String() != "950.00" || !s.Cleared.IsZero() || s.Outstanding.String() != "-40.00" || s.Balance().String() != "910.00" {
+		t.Errorf("summary: yields %+v: expected 950.00, 0.00, -40.00\n", s)
+	}
+}
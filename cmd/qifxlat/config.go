@@ -27,18 +27,36 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/maloquacious/qif/stdlib"
 	"github.com/peterbourgon/ff/v3"
 	"os"
 )
 
 type Config struct {
 	Input struct {
-		QIF string
+		QIF          string
+		Previous     string
+		Lenient      bool
+		Passthrough  bool
+		Workers      int
+		EncodingName string
+		Encoding     stdlib.Encoding
+		DateFormat   string
+		CenturyPivot int
+		Dates        stdlib.DateOptions
+	}
+	Budget struct {
+		Year int
 	}
 	Output struct {
-		CSV    string
-		JSON   string
-		Ledger string
+		BudgetCSV    string
+		BudgetReport string
+		CSV          string
+		JSON         string
+		Ledger       string
+		LedgerPrices string
+		QIF          string
+		Reconcile    string
 	}
 	Show struct {
 		Timing bool
@@ -47,13 +65,29 @@ type Config struct {
 
 func config() (*Config, error) {
 	cfg := Config{}
+	cfg.Input.DateFormat = stdlib.DateAuto.String()
+	cfg.Input.EncodingName = stdlib.EncodingAuto.String()
+	cfg.Input.CenturyPivot = stdlib.DefaultCenturyPivot
 	cfg.Show.Timing = true
 
 	fs := flag.NewFlagSet("qifxlat", flag.ExitOnError)
 	fs.StringVar(&cfg.Input.QIF, "input", "", "QIF file to translate")
+	fs.StringVar(&cfg.Input.Previous, "since-previous", cfg.Input.Previous, "QIF file from the previous import; only transactions that aren't in it are translated")
+	fs.BoolVar(&cfg.Input.Lenient, "lenient", cfg.Input.Lenient, "report malformed records instead of stopping at the first one")
+	fs.BoolVar(&cfg.Input.Passthrough, "passthrough", cfg.Input.Passthrough, "keep unknown fields and sections instead of rejecting them")
+	fs.IntVar(&cfg.Input.Workers, "workers", cfg.Input.Workers, "number of goroutines that parse transaction sections (0 parses on one)")
+	fs.StringVar(&cfg.Input.EncodingName, "input-encoding", cfg.Input.EncodingName, "encoding of the QIF file (auto, utf-8, utf-16le, utf-16be, windows-1252, iso-8859-1)")
+	fs.StringVar(&cfg.Input.DateFormat, "date-format", cfg.Input.DateFormat, "format of dates in the QIF file (auto, us, european, iso, quicken)")
+	fs.IntVar(&cfg.Input.CenturyPivot, "century-pivot", cfg.Input.CenturyPivot, "two-digit years below this are in the 21st century")
+	fs.IntVar(&cfg.Budget.Year, "budget-year", cfg.Budget.Year, "year for the budget report (default is the year of the latest transaction)")
+	fs.StringVar(&cfg.Output.BudgetCSV, "output-budget-csv-filename", cfg.Output.BudgetCSV, "file to write the budget grid to as CSV")
+	fs.StringVar(&cfg.Output.BudgetReport, "output-budget-report-filename", cfg.Output.BudgetReport, "file to write the budget-vs-actual report to as CSV")
 	fs.StringVar(&cfg.Output.CSV, "output-csv-filename", cfg.Output.CSV, "file to write CSV data to")
 	fs.StringVar(&cfg.Output.JSON, "output-json-filename", cfg.Output.JSON, "file to write JSON data to")
 	fs.StringVar(&cfg.Output.Ledger, "output-ledger-filename", cfg.Output.Ledger, "file to write Ledger data to")
+	fs.StringVar(&cfg.Output.LedgerPrices, "output-ledger-prices-filename", cfg.Output.LedgerPrices, "file to write the Ledger price database to (default is the Ledger file)")
+	fs.StringVar(&cfg.Output.QIF, "output-qif-filename", cfg.Output.QIF, "file to write QIF data to")
+	fs.StringVar(&cfg.Output.Reconcile, "output-reconciliation-filename", cfg.Output.Reconcile, "file to write the reconciled, cleared and outstanding totals of each account to as CSV")
 	fs.BoolVar(&cfg.Show.Timing, "show-timing", cfg.Show.Timing, "display timing of stages")
 	_ = fs.String("config", "", "config file (optional)")
 
@@ -65,7 +99,47 @@ func config() (*Config, error) {
 		return nil, fmt.Errorf("please provide the name of the QIF file to translate\n")
 	}
 	fmt.Printf("%-30s == %q\n", "QIFXLAT_INPUT", cfg.Input.QIF)
+	if cfg.Input.Previous != "" {
+		fmt.Printf("%-30s == %q\n", "QIFXLAT_SINCE_PREVIOUS", cfg.Input.Previous)
+	}
+	if cfg.Input.Lenient {
+		fmt.Printf("%-30s == %v\n", "QIFXLAT_LENIENT", cfg.Input.Lenient)
+	}
+	if cfg.Input.Passthrough {
+		fmt.Printf("%-30s == %v\n", "QIFXLAT_PASSTHROUGH", cfg.Input.Passthrough)
+	}
+	if cfg.Input.Workers < 0 {
+		return nil, fmt.Errorf("workers must not be negative\n")
+	} else if cfg.Input.Workers > 1 {
+		fmt.Printf("%-30s == %d\n", "QIFXLAT_WORKERS", cfg.Input.Workers)
+	}
+	if encoding, err := stdlib.ParseEncoding(cfg.Input.EncodingName); err != nil {
+		return nil, err
+	} else {
+		cfg.Input.Encoding = encoding
+	}
+	fmt.Printf("%-30s == %q\n", "QIFXLAT_INPUT_ENCODING", cfg.Input.Encoding)
+	if format, err := stdlib.ParseDateFormat(cfg.Input.DateFormat); err != nil {
+		return nil, err
+	} else if !(1 <= cfg.Input.CenturyPivot && cfg.Input.CenturyPivot <= 100) {
+		return nil, fmt.Errorf("century-pivot must be between 1 and 100\n")
+	} else {
+		cfg.Input.Dates = stdlib.DateOptions{Format: format, CenturyPivot: cfg.Input.CenturyPivot}
+	}
+	fmt.Printf("%-30s == %q\n", "QIFXLAT_DATE_FORMAT", cfg.Input.Dates.Format)
+	fmt.Printf("%-30s == %d\n", "QIFXLAT_CENTURY_PIVOT", cfg.Input.Dates.CenturyPivot)
 	outputFileSpecified := false
+	if cfg.Budget.Year != 0 {
+		fmt.Printf("%-30s == %d\n", "QIFXLAT_BUDGET_YEAR", cfg.Budget.Year)
+	}
+	if cfg.Output.BudgetCSV != "" {
+		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_BUDGET_CSV_FILENAME", cfg.Output.BudgetCSV)
+		outputFileSpecified = true
+	}
+	if cfg.Output.BudgetReport != "" {
+		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_BUDGET_REPORT_FILENAME", cfg.Output.BudgetReport)
+		outputFileSpecified = true
+	}
 	if cfg.Output.CSV != "" {
 		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_CSV_FILENAME", cfg.Output.CSV)
 		outputFileSpecified = true
@@ -78,6 +152,18 @@ func config() (*Config, error) {
 		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_LEDGER_FILENAME", cfg.Output.Ledger)
 		outputFileSpecified = true
 	}
+	if cfg.Output.LedgerPrices != "" {
+		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_LEDGER_PRICES_FILENAME", cfg.Output.LedgerPrices)
+		outputFileSpecified = true
+	}
+	if cfg.Output.QIF != "" {
+		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_QIF_FILENAME", cfg.Output.QIF)
+		outputFileSpecified = true
+	}
+	if cfg.Output.Reconcile != "" {
+		fmt.Printf("%-30s == %q\n", "QIFXLAT_OUTPUT_RECONCILIATION_FILENAME", cfg.Output.Reconcile)
+		outputFileSpecified = true
+	}
 	if !outputFileSpecified {
 		fmt.Printf("warning: no output file(s) specified; will validate QIF data only\n")
 	}
@@ -27,11 +27,16 @@ package main
 
 import (
 	"fmt"
+	"github.com/maloquacious/qif/balance"
+	"github.com/maloquacious/qif/dedup"
+	"github.com/maloquacious/qif/normalizer"
 	"github.com/maloquacious/qif/reader"
 	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
 	cdata "github.com/maloquacious/qif/writer/csv"
 	jdata "github.com/maloquacious/qif/writer/json"
 	ldata "github.com/maloquacious/qif/writer/ledger"
+	qdata "github.com/maloquacious/qif/writer/qif"
 	"io/ioutil"
 	"os"
 	"time"
@@ -50,23 +55,40 @@ func main() {
 	}
 }
 
-func run(cfg *Config) error {
-	started := time.Now()
-
-	input, err := ioutil.ReadFile(cfg.Input.QIF)
+// load reads a QIF file with the input options.
+func load(name, stage string, cfg *Config) (*reader.Reader, []reader.Diagnostic, error) {
+	input, err := ioutil.ReadFile(name)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	sc, err := scanner.New(input)
+	sc, err := scanner.NewWithEncoding(input, cfg.Input.Encoding)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if cfg.Input.Encoding == stdlib.EncodingAuto {
+		fmt.Printf("%s: detected %s encoding\n", stage, sc.Encoding)
 	}
 
-	r, err := reader.Read(sc)
+	return reader.ReadWithOptions(sc, reader.Options{Lenient: cfg.Input.Lenient, Dates: cfg.Input.Dates, Passthrough: cfg.Input.Passthrough, Workers: cfg.Input.Workers})
+}
+
+func run(cfg *Config) error {
+	started := time.Now()
+
+	r, diagnostics, err := load(cfg.Input.QIF, "import", cfg)
 	if err != nil {
 		return err
 	}
+	for _, d := range diagnostics {
+		fmt.Printf("import: %s\n", d)
+	}
+	if len(diagnostics) != 0 {
+		fmt.Printf("import: found %8d malformed records\n", len(diagnostics))
+	}
+	for _, raw := range r.Unknown {
+		fmt.Printf("import: %d: kept unknown section %q\n", raw.Line, raw.Header)
+	}
 
 	var totalRecords int
 	if r.Accounts == nil {
@@ -75,12 +97,22 @@ func run(cfg *Config) error {
 		fmt.Printf("import: read %8d accounts\n", len(r.Accounts.Records))
 		totalRecords += len(r.Accounts.Records)
 	}
+	fmt.Printf("import: read %8d bills\n", len(r.Bills))
+	totalRecords += len(r.Bills)
 	if r.Categories == nil {
 		fmt.Printf("import: read %8d categories\n", 0)
 	} else {
 		fmt.Printf("import: read %8d categories\n", len(r.Categories.Records))
 		totalRecords += len(r.Categories.Records)
 	}
+	if r.Classes == nil {
+		fmt.Printf("import: read %8d classes\n", 0)
+	} else {
+		fmt.Printf("import: read %8d classes\n", len(r.Classes.Records))
+		totalRecords += len(r.Classes.Records)
+	}
+	fmt.Printf("import: read %8d invoices\n", len(r.Invoices))
+	totalRecords += len(r.Invoices)
 	fmt.Printf("import: read %8d memorized\n", len(r.Memorized))
 	totalRecords += len(r.Memorized)
 	fmt.Printf("import: read %8d prices\n", len(r.Prices))
@@ -99,11 +131,177 @@ func run(cfg *Config) error {
 	fmt.Printf("import: read %8d transactions\n", len(r.Transactions))
 	totalRecords += len(r.Transactions)
 
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return err
+	}
+
+	// flag transactions that use categories missing from the category list
+	if r.Categories != nil {
+		undefined := normalizer.UndefinedCategories(r.Categories.Records, transactions)
+		for _, u := range undefined {
+			fmt.Printf("check: %s\n", u)
+		}
+		if len(undefined) != 0 {
+			fmt.Printf("check: found %8d undefined categories\n", len(undefined))
+		}
+	}
+
+	// flag transfers that don't have a matching transaction in the other account
+	unmatched := normalizer.UnmatchedTransfers(transactions)
+	for _, u := range unmatched {
+		fmt.Printf("check: %s\n", u)
+	}
+	if len(unmatched) != 0 {
+		fmt.Printf("check: found %8d unmatched transfers\n", len(unmatched))
+	}
+
+	// flag transactions with cleared statuses that Quicken doesn't write
+	statuses := append(normalizer.UnknownClearedStatuses(r.Transactions), normalizer.UnknownClearedStatuses(r.Memorized)...)
+	for _, u := range statuses {
+		fmt.Printf("check: %s\n", u)
+	}
+	if len(statuses) != 0 {
+		fmt.Printf("check: found %8d unknown cleared statuses\n", len(statuses))
+	}
+
+	// flag memorized payees with types that Quicken doesn't write
+	memorizedTypes := normalizer.UnknownMemorizedTypes(r.Memorized)
+	for _, u := range memorizedTypes {
+		fmt.Printf("check: %s\n", u)
+	}
+	if len(memorizedTypes) != 0 {
+		fmt.Printf("check: found %8d unknown memorized types\n", len(memorizedTypes))
+	}
+
+	// flag investment transactions with actions that Quicken doesn't define
+	unknown := normalizer.UnknownActions(transactions)
+	for _, u := range unknown {
+		fmt.Printf("check: %s\n", u)
+	}
+	if len(unknown) != 0 {
+		fmt.Printf("check: found %8d unknown investment actions\n", len(unknown))
+	}
+
+	// flag buys and sells whose amounts don't add up
+	investments, err := normalizer.Investments(r.Transactions)
+	if err != nil {
+		return err
+	}
+	mismatches := normalizer.AmountMismatches(investments)
+	for _, m := range mismatches {
+		fmt.Printf("check: %s\n", m)
+	}
+	if len(mismatches) != 0 {
+		fmt.Printf("check: found %8d investment amount mismatches\n", len(mismatches))
+	}
+
+	// flag split transactions whose lines are malformed or don't add up
+	issues := normalizer.ValidateSplits(transactions, normalizer.SplitOptions{})
+	for _, issue := range issues {
+		fmt.Printf("check: %s\n", issue)
+	}
+	if len(issues) != 0 {
+		fmt.Printf("check: found %8d split problems\n", len(issues))
+	}
+
+	// flag accounts whose outstanding transactions don't explain the statement balance
+	if r.Accounts != nil {
+		reconciliations, err := balance.Reconcile(r.Accounts.Records, balance.Registers(transactions))
+		if err != nil {
+			return err
+		}
+		var unbalanced int
+		for _, rec := range reconciliations {
+			if !rec.IsBalanced() {
+				fmt.Printf("check: %s\n", rec)
+				unbalanced++
+			}
+		}
+		if unbalanced != 0 {
+			fmt.Printf("check: found %8d accounts that don't reconcile\n", unbalanced)
+		}
+	}
+
 	if cfg.Show.Timing {
 		duration := time.Now().Sub(started)
 		fmt.Printf("import: finished in %v\n", duration)
 	}
 
+	// the checks and reports need the whole import, but the translations
+	// only get the transactions that weren't in the previous import
+	translate := r
+	if cfg.Input.Previous != "" {
+		previous, _, err := load(cfg.Input.Previous, "previous", cfg)
+		if err != nil {
+			return err
+		}
+		result, err := dedup.Compare(r, previous, dedup.DefaultOptions)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("dedup: skipped %8d duplicate transactions\n", len(result.Duplicates))
+		fmt.Printf("dedup: kept    %8d new transactions\n", len(result.New))
+		translate = result.Apply(r)
+	}
+
+	if cfg.Output.BudgetCSV != "" {
+		started := time.Now()
+
+		fp, err := os.Create(cfg.Output.BudgetCSV)
+		if err != nil {
+			return err
+		}
+		data, err := cdata.TranslateBudget(r)
+		if err != nil {
+			return err
+		}
+		err = data.Write(fp)
+		if err != nil {
+			return err
+		}
+		err = fp.Close()
+		if err != nil {
+			return err
+		}
+
+		if cfg.Show.Timing {
+			duration := time.Now().Sub(started)
+			fmt.Printf("budget: finished in %v\n", duration)
+		}
+	}
+
+	if cfg.Output.BudgetReport != "" {
+		started := time.Now()
+
+		year := cfg.Budget.Year
+		if year == 0 {
+			year = normalizer.BudgetYear(r.Transactions)
+		}
+
+		fp, err := os.Create(cfg.Output.BudgetReport)
+		if err != nil {
+			return err
+		}
+		data, err := cdata.TranslateBudgetReport(r, year)
+		if err != nil {
+			return err
+		}
+		err = data.Write(fp)
+		if err != nil {
+			return err
+		}
+		err = fp.Close()
+		if err != nil {
+			return err
+		}
+
+		if cfg.Show.Timing {
+			duration := time.Now().Sub(started)
+			fmt.Printf("budget report: finished in %v\n", duration)
+		}
+	}
+
 	if cfg.Output.CSV != "" {
 		started := time.Now()
 
@@ -111,7 +309,7 @@ func run(cfg *Config) error {
 		if err != nil {
 			return err
 		}
-		data, err := cdata.Translate(r)
+		data, err := cdata.Translate(translate)
 		if err != nil {
 			return err
 		}
@@ -137,7 +335,7 @@ func run(cfg *Config) error {
 		if err != nil {
 			return err
 		}
-		data, err := jdata.Translate(r)
+		data, err := jdata.Translate(translate)
 		if err != nil {
 			return err
 		}
@@ -163,10 +361,13 @@ func run(cfg *Config) error {
 		if err != nil {
 			return err
 		}
-		data, err := ldata.Translate(r)
+		data, err := ldata.Translate(translate)
 		if err != nil {
 			return err
 		}
+		if cfg.Output.LedgerPrices != "" {
+			data.Prices = nil // they go in their own file
+		}
 		err = data.Write(fp)
 		if err != nil {
 			return err
@@ -182,6 +383,84 @@ func run(cfg *Config) error {
 		}
 	}
 
+	if cfg.Output.LedgerPrices != "" {
+		started := time.Now()
+
+		fp, err := os.Create(cfg.Output.LedgerPrices)
+		if err != nil {
+			return err
+		}
+		data, err := ldata.TranslatePrices(r)
+		if err != nil {
+			return err
+		}
+		err = data.Write(fp)
+		if err != nil {
+			return err
+		}
+		err = fp.Close()
+		if err != nil {
+			return err
+		}
+
+		if cfg.Show.Timing {
+			duration := time.Now().Sub(started)
+			fmt.Printf("prices: finished in %v\n", duration)
+		}
+	}
+
+	if cfg.Output.QIF != "" {
+		started := time.Now()
+
+		fp, err := os.Create(cfg.Output.QIF)
+		if err != nil {
+			return err
+		}
+		data, err := qdata.Translate(translate)
+		if err != nil {
+			return err
+		}
+		err = data.Write(fp)
+		if err != nil {
+			return err
+		}
+		err = fp.Close()
+		if err != nil {
+			return err
+		}
+
+		if cfg.Show.Timing {
+			duration := time.Now().Sub(started)
+			fmt.Printf("qif: finished in %v\n", duration)
+		}
+	}
+
+	if cfg.Output.Reconcile != "" {
+		started := time.Now()
+
+		fp, err := os.Create(cfg.Output.Reconcile)
+		if err != nil {
+			return err
+		}
+		data, err := cdata.TranslateReconciliation(r)
+		if err != nil {
+			return err
+		}
+		err = data.Write(fp)
+		if err != nil {
+			return err
+		}
+		err = fp.Close()
+		if err != nil {
+			return err
+		}
+
+		if cfg.Show.Timing {
+			duration := time.Now().Sub(started)
+			fmt.Printf("reconciliation: finished in %v\n", duration)
+		}
+	}
+
 	if cfg.Show.Timing {
 		duration := time.Now().Sub(started)
 		fmt.Printf("qif: finished run  in %v\n", duration)
@@ -0,0 +1,168 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/maloquacious/qif/stdlib"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSincePrevious(t *testing.T) {
+	// Specification: Translating only the new activity
+
+	// Given a previous download and a new one that overlaps it, with a
+	// statement balance that covers both and a transfer that posted to
+	// savings after the previous download
+	accounts := `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+$850.00
+/2/ 1'16
+^
+NSavings
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+`
+	previous := accounts + `D1/ 1'16
+CX
+POpening Balance
+T1,000.00
+L[Checking]
+^
+D1/ 4'16
+CX
+PGrocer
+T-50.00
+^
+D1/31'16
+CX
+PTransfer
+T-100.00
+L[Savings]
+^
+`
+	current := accounts + `D1/ 1'16
+CX
+POpening Balance
+T1,000.00
+L[Checking]
+^
+D1/ 4'16
+CX
+PGrocer
+T-50.00
+^
+D1/31'16
+CX
+PTransfer
+T-100.00
+L[Savings]
+^
+D2/ 1'16
+PPaycheck
+T1,000.00
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D1/31'16
+PTransfer
+T100.00
+L[Checking]
+^
+`
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cfg := &Config{}
+	cfg.Input.QIF = write("current.qif", current)
+	cfg.Input.Previous = write("previous.qif", previous)
+	cfg.Input.Dates = stdlib.DateOptions{Format: stdlib.DateAuto, CenturyPivot: stdlib.DefaultCenturyPivot}
+	cfg.Output.CSV = filepath.Join(dir, "new.csv")
+	cfg.Output.Reconcile = filepath.Join(dir, "reconcile.csv")
+
+	// When it is translated since the previous download
+	output := capture(t, func() error { return run(cfg) })
+
+	// Then the checks see every transaction
+	if strings.Contains(output, "don't reconcile") || strings.Contains(output, "unmatched transfer") {
+		t.Errorf("checks: yields %q: expected no problems\n", output)
+	}
+
+	// And the reconciliation report covers every transaction
+	if data, err := ioutil.ReadFile(cfg.Output.Reconcile); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), "Checking,850.00,0.00,1000.00,1850.00") {
+		t.Errorf("reconciliation: yields %q: expected the whole register\n", data)
+	}
+
+	// And the translation only has the new transactions
+	if data, err := ioutil.ReadFile(cfg.Output.CSV); err != nil {
+		t.Fatal(err)
+	} else if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 || !strings.Contains(lines[1], "Savings,43,Checking,,,100.00") || !strings.Contains(lines[2], "Paycheck") {
+		t.Errorf("csv: yields %q: expected the savings transfer and the paycheck\n", lines)
+	}
+}
+
+// capture returns what fn writes to stdout.
+func capture(t *testing.T, fn func() error) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		done <- data
+	}()
+	err = fn()
+	os.Stdout = stdout
+	_ = w.Close()
+	output := string(<-done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
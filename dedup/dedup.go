@@ -0,0 +1,272 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package dedup finds transactions that were already imported. Bank
+// downloads overlap from one month to the next, so the same transaction
+// can show up with a date that is off by a few days or a payee that is
+// spelled a little differently.
+package dedup
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+	"strings"
+	"unicode"
+)
+
+// Options controls how close two transactions must be to be duplicates.
+type Options struct {
+	// DateWindow is the number of days that the dates can differ by.
+	DateWindow int
+	// Similarity is the lowest payee similarity, from 0 to 1, that
+	// counts as the same payee. 1 requires the same payee after case
+	// and punctuation are ignored.
+	Similarity float64
+}
+
+// DefaultOptions allows for the few days that a bank takes to post a
+// transaction and for small differences in the payee.
+var DefaultOptions = Options{DateWindow: 3, Similarity: 0.8}
+
+// Fingerprint is the part of a transaction that is compared.
+type Fingerprint struct {
+	Account string
+	Date    stdlib.CivilDate
+	Amount  stdlib.Amount
+	Payee   string // lower case, with punctuation and extra spaces removed
+	RefNo   string
+}
+
+// NewFingerprint returns the fingerprint of a normalized transaction.
+func NewFingerprint(t *normalizer.Transaction) Fingerprint {
+	return Fingerprint{
+		Account: t.Account,
+		Date:    t.Date,
+		Amount:  t.Total,
+		Payee:   normalize(t.Payee),
+		RefNo:   strings.TrimSpace(t.RefNo),
+	}
+}
+
+// Matches returns true if the fingerprints are for the same transaction.
+// The account and amount must be the same and the dates must be within
+// the window. If both have a reference number, they must have the same
+// one. Otherwise, the payees must be similar.
+func (f Fingerprint) Matches(g Fingerprint, opts Options) bool {
+	if f.Account != g.Account || f.Amount.Cmp(g.Amount) != 0 {
+		return false
+	} else if abs(f.Date.DaysSince(g.Date)) > opts.DateWindow {
+		return false
+	} else if f.RefNo != "" && g.RefNo != "" {
+		return f.RefNo == g.RefNo
+	}
+	return Similarity(f.Payee, g.Payee) >= opts.Similarity
+}
+
+// Duplicate is a transaction that matches one that was already seen.
+type Duplicate struct {
+	Record   *transaction.Record
+	Original *transaction.Record // from the previous import, or earlier in the same one
+}
+
+func (d Duplicate) String() string {
+	return fmt.Sprintf("%d: duplicate of %d: %s %q %s", d.Record.Line, d.Original.Line, d.Record.Date, d.Record.Payee, d.Record.AmountTCode)
+}
+
+// Result splits the transactions into duplicates and new activity. Both
+// are in the order of the input.
+type Result struct {
+	Duplicates []Duplicate
+	New        []*transaction.Record
+}
+
+// Apply returns a copy of the reader with only the new transactions.
+// The reader isn't changed, so it can still be used for checks that need
+// every transaction (eg, reconciling statement balances).
+func (res *Result) Apply(r *reader.Reader) *reader.Reader {
+	applied := *r
+	applied.Transactions = res.New
+	return &applied
+}
+
+// Compare finds the transactions in current that were already in
+// previous. Each previous transaction matches at most one current
+// transaction, so repeated purchases aren't mistaken for duplicates. When
+// several could match, the one with the closest date is used.
+func Compare(current, previous *reader.Reader, opts Options) (*Result, error) {
+	seen, err := newIndex(previous.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := normalizer.Transactions(current.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	var result Result
+	for i, t := range transactions {
+		if original := seen.take(NewFingerprint(t), opts); original != nil {
+			result.Duplicates = append(result.Duplicates, Duplicate{Record: current.Transactions[i], Original: original})
+		} else {
+			result.New = append(result.New, current.Transactions[i])
+		}
+	}
+	return &result, nil
+}
+
+// Self finds transactions that match an earlier transaction in the same
+// import.
+func Self(r *reader.Reader, opts Options) (*Result, error) {
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	seen := &index{entries: make(map[key][]*entry)}
+	var result Result
+	for i, t := range transactions {
+		fp := NewFingerprint(t)
+		if original := seen.take(fp, opts); original != nil {
+			result.Duplicates = append(result.Duplicates, Duplicate{Record: r.Transactions[i], Original: original})
+			continue
+		}
+		result.New = append(result.New, r.Transactions[i])
+		seen.add(fp, r.Transactions[i])
+	}
+	return &result, nil
+}
+
+// key groups the fingerprints that can match, since the account and
+// amount must be the same.
+type key struct {
+	account string
+	amount  stdlib.Amount
+}
+
+type entry struct {
+	fp     Fingerprint
+	record *transaction.Record
+	used   bool
+}
+
+type index struct {
+	entries map[key][]*entry
+}
+
+func newIndex(records []*transaction.Record) (*index, error) {
+	transactions, err := normalizer.Transactions(records)
+	if err != nil {
+		return nil, err
+	}
+	x := &index{entries: make(map[key][]*entry)}
+	for i, t := range transactions {
+		x.add(NewFingerprint(t), records[i])
+	}
+	return x, nil
+}
+
+func (x *index) add(fp Fingerprint, record *transaction.Record) {
+	k := key{account: fp.Account, amount: fp.Amount}
+	x.entries[k] = append(x.entries[k], &entry{fp: fp, record: record})
+}
+
+// take returns the closest unused match and marks it as used. It returns
+// nil if there isn't one.
+func (x *index) take(fp Fingerprint, opts Options) *transaction.Record {
+	var best *entry
+	for _, e := range x.entries[key{account: fp.Account, amount: fp.Amount}] {
+		if e.used || !fp.Matches(e.fp, opts) {
+			continue
+		}
+		if best == nil || abs(fp.Date.DaysSince(e.fp.Date)) < abs(fp.Date.DaysSince(best.fp.Date)) {
+			best = e
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	best.used = true
+	return best.record
+}
+
+// Similarity returns how alike two payees are, from 0 for nothing in
+// common to 1 for the same. It is one less the edit distance divided by
+// the length of the longer payee.
+func Similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(distance(ra, rb))/float64(longest)
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b []rune) int {
+	prev, curr := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// normalize folds case and replaces runs of punctuation and spaces with
+// a single space.
+func normalize(payee string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(payee), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,165 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package dedup_test
+
+import (
+	"github.com/maloquacious/qif/dedup"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"strings"
+	"testing"
+)
+
+// register returns a checking account with the transactions.
+func register(t *testing.T, transactions string) *reader.Reader {
+	sc, err := scanner.New([]byte("!Account\nNChecking\nTBank\n^\n!Account\nNChecking\nTBank\n^\n!Type:Bank\n" + transactions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestCompare(t *testing.T) {
+	// Specification: Duplicates across imports
+
+	// Given a previous import
+	previous := register(t, `D1/28'16
+PGrocer #12.
+T-54.25
+^
+D1/29'16
+PCoffee Shop
+T-4.50
+^
+D1/30'16
+N1001
+PLandlord
+T-900.00
+^
+D1/30'16
+N1002
+PPlumber
+T-150.00
+^
+`)
+
+	// And a new import that overlaps it, with the grocer posted two days
+	// later and spelled differently, the same coffee bought twice, a
+	// check with the same number but a different payee and a check with
+	// a different number
+	current := register(t, `D1/30'16
+PGROCER #12
+T-54.25
+^
+D1/29'16
+PCoffee Shop
+T-4.50
+^
+D1/29'16
+PCoffee Shop
+T-4.50
+^
+D1/30'16
+N1001
+PLandlord LLC
+T-900.00
+^
+D1/31'16
+N1003
+PPlumber
+T-150.00
+^
+D2/ 1'16
+PPaycheck
+T1,000.00
+^
+`)
+
+	// When they are compared
+	result, err := dedup.Compare(current, previous, dedup.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then the overlap is reported as duplicates
+	var duplicates []string
+	for _, d := range result.Duplicates {
+		duplicates = append(duplicates, d.Record.Payee)
+	}
+	if yields, expected := strings.Join(duplicates, ", "), "GROCER #12, Coffee Shop, Landlord LLC"; yields != expected {
+		t.Errorf("duplicates: yields %q: expected %q\n", yields, expected)
+	}
+
+	// And only the new activity is kept
+	var kept []string
+	for _, r := range result.New {
+		kept = append(kept, r.Payee)
+	}
+	if yields, expected := strings.Join(kept, ", "), "Coffee Shop, Plumber, Paycheck"; yields != expected {
+		t.Errorf("new: yields %q: expected %q\n", yields, expected)
+	}
+}
+
+func TestSelf(t *testing.T) {
+	// Specification: Duplicates within an import
+
+	// Given an import that repeats a transaction a day later
+	r := register(t, `D1/28'16
+PGrocer
+T-54.25
+^
+D1/29'16
+PGrocer
+T-54.25
+^
+D1/29'16
+PDiner
+T-54.25
+^
+`)
+
+	// When it is compared with itself
+	result, err := dedup.Self(r, dedup.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then the repeat is a duplicate of the first one
+	if len(result.Duplicates) != 1 || result.Duplicates[0].Record.Line != 14 || result.Duplicates[0].Original.Line != 10 {
+		t.Errorf("duplicates: yields %v: expected 14 to duplicate 10\n", result.Duplicates)
+	}
+	if len(result.New) != 2 {
+		t.Errorf("new: yields %d: expected 2\n", len(result.New))
+	}
+
+	// And payees that differ by a letter are similar
+	if s := dedup.Similarity("grocer", "grocers"); s < dedup.DefaultOptions.Similarity {
+		t.Errorf("similarity: yields %v: expected at least %v\n", s, dedup.DefaultOptions.Similarity)
+	}
+}
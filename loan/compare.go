@@ -0,0 +1,140 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package loan
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/stdlib"
+	"sort"
+)
+
+// Problem is the kind of difference between the schedule and the
+// payments that were recorded.
+type Problem string
+
+const (
+	Missed      Problem = "missed"       // no payment near the due date
+	WrongAmount Problem = "wrong amount" // the principal doesn't match the schedule
+	Unscheduled Problem = "unscheduled"  // a payment that doesn't match any due date
+)
+
+// Finding is a difference between the schedule and the payments.
+type Finding struct {
+	Problem   Problem
+	Scheduled *Payment                // nil for unscheduled payments
+	Actual    *normalizer.Transaction // nil for missed payments
+}
+
+func (f Finding) String() string {
+	switch {
+	case f.Scheduled == nil:
+		return fmt.Sprintf("%d: %s: %s payment of %s", f.Actual.Line, f.Actual.Date, f.Problem, f.Actual.Total)
+	case f.Actual == nil:
+		return fmt.Sprintf("payment %d: %s: %s payment of %s", f.Scheduled.Number, f.Scheduled.Date, f.Problem, f.Scheduled.Principal)
+	}
+	return fmt.Sprintf("%d: %s: %s: paid %s, scheduled %s", f.Actual.Line, f.Actual.Date, f.Problem, f.Actual.Total, f.Scheduled.Principal)
+}
+
+// CompareOptions controls how payments are matched to the schedule.
+type CompareOptions struct {
+	// GraceDays is how far from the due date a payment can be and
+	// still count as that payment.
+	GraceDays int
+	// Through is the last date to check. The zero date means the date
+	// of the last payment.
+	Through stdlib.CivilDate
+}
+
+// Compare matches the payments recorded in the loan account against the
+// schedule. The loan register only holds the principal of each payment,
+// so that is what the amounts are compared with. Transactions from other
+// accounts and transactions that increase the loan are ignored.
+//
+// Findings are sorted by date.
+func (s *Schedule) Compare(transactions []*normalizer.Transaction, opts CompareOptions) []Finding {
+	var actual []*normalizer.Transaction
+	for _, t := range transactions {
+		if t.Account == s.Account && t.Type == "Oth L" && t.Total.Sign() > 0 {
+			actual = append(actual, t)
+		}
+	}
+	sort.SliceStable(actual, func(i, j int) bool {
+		return actual[i].Date.Before(actual[j].Date)
+	})
+
+	through := opts.Through
+	if through.IsZero() && len(actual) != 0 {
+		through = actual[len(actual)-1].Date
+	}
+
+	var findings []Finding
+	used := make([]bool, len(actual))
+	for _, p := range s.Payments {
+		if p.Date.After(through) {
+			break
+		}
+		match := -1
+		for i, t := range actual {
+			if !used[i] && abs(t.Date.DaysSince(p.Date)) <= opts.GraceDays {
+				match = i
+				break
+			}
+		}
+		if match == -1 {
+			findings = append(findings, Finding{Problem: Missed, Scheduled: p})
+			continue
+		}
+		used[match] = true
+		if !actual[match].Total.WithinCent(p.Principal) {
+			findings = append(findings, Finding{Problem: WrongAmount, Scheduled: p, Actual: actual[match]})
+		}
+	}
+	for i, t := range actual {
+		if !used[i] && !t.Date.After(through) {
+			findings = append(findings, Finding{Problem: Unscheduled, Actual: t})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].date().Before(findings[j].date())
+	})
+	return findings
+}
+
+// date returns the date that the finding is about.
+func (f Finding) date() stdlib.CivilDate {
+	if f.Actual != nil {
+		return f.Actual.Date
+	}
+	return f.Scheduled.Date
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,132 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package loan_test
+
+import (
+	"github.com/maloquacious/qif/loan"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+)
+
+func TestSchedule(t *testing.T) {
+	// Specification: Loan schedule
+
+	// Given a memorized mortgage payment and some payments in the
+	// mortgage account
+	input := `!Option:AutoSwitch
+!Account
+NMortgage
+TOth L
+^
+!Clear:AutoSwitch
+!Type:Memorized
+KP
+T-1,157.79
+PFirst Mortgage Co
+L[Mortgage]
+1 2/ 1'16
+230
+412
+53.75
+7250,000.00
+^
+!Account
+NMortgage
+TOth L
+^
+!Type:Oth L
+D1/15'16
+T-250,000.00
+POpening Balance
+^
+D2/ 1'16
+T376.54
+PFirst Mortgage Co
+^
+D3/ 3'16
+T377.00
+PFirst Mortgage Co
+^
+D4/20'16
+T1,000.00
+PExtra principal
+^
+D5/ 1'16
+T380.08
+PFirst Mortgage Co
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memorized, err := normalizer.MemorizedPayees(r.Memorized, r.Dates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the schedule is built
+	s, err := loan.NewSchedule(memorized[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then it has a level payment that pays off the loan
+	if s.Account != "Mortgage" || len(s.Payments) != 360 {
+		t.Fatalf("schedule: yields %q with %d payments: expected %q with 360\n", s.Account, len(s.Payments), "Mortgage")
+	}
+	first, last := s.Payments[0], s.Payments[359]
+	if first.Date.String() != "2016-02-01" || first.Amount.String() != "1157.79" || first.Interest.String() != "781.25" || first.Principal.String() != "376.54" || first.Balance.String() != "249623.46" {
+		t.Errorf("first: yields %s %s = %s + %s leaving %s\n", first.Date, first.Amount, first.Principal, first.Interest, first.Balance)
+	}
+	if last.Date.String() != "2046-01-01" || !last.Balance.IsZero() {
+		t.Errorf("last: yields %s leaving %s: expected 2046-01-01 leaving 0.00\n", last.Date, last.Balance)
+	}
+
+	// When it is compared with the payments in the mortgage account
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := s.Compare(transactions, loan.CompareOptions{GraceDays: 5})
+
+	// Then the wrong amount, the missed payment and the extra payment
+	// are reported in date order
+	expected := []loan.Problem{loan.WrongAmount, loan.Missed, loan.Unscheduled}
+	if len(findings) != len(expected) {
+		t.Fatalf("compare: yields %v: expected %v\n", findings, expected)
+	}
+	for i, f := range findings {
+		if f.Problem != expected[i] {
+			t.Errorf("compare: finding %d yields %q: expected %q\n", i, f.Problem, expected[i])
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package loan builds amortization schedules from Quicken's memorized
+// loan payments and compares them with the payments that were recorded.
+package loan
+
+import (
+	"errors"
+	"fmt"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/stdlib"
+	"math"
+	"strconv"
+)
+
+// ErrNoSchedule is returned when a memorized payee doesn't have enough
+// amortization fields to build a schedule.
+var ErrNoSchedule = errors.New("no amortization schedule")
+
+// Payment is one scheduled payment.
+type Payment struct {
+	Number    int
+	Date      stdlib.CivilDate
+	Amount    stdlib.Amount // principal plus interest
+	Principal stdlib.Amount
+	Interest  stdlib.Amount
+	Balance   stdlib.Amount // remaining after the payment
+}
+
+// Schedule is the amortization schedule for a loan.
+type Schedule struct {
+	Payee    string
+	Account  string // the Oth L account that tracks the loan
+	Payments []*Payment
+}
+
+// NewSchedule returns the schedule for a memorized loan payment. It
+// needs the first payment date, the term, the number of payments per
+// year and the original loan amount. The interest rate may be zero.
+//
+// Every payment is the same amount, rounded to cents, except for the
+// last, which pays off whatever is left.
+func NewSchedule(m *normalizer.MemorizedPayee) (*Schedule, error) {
+	a := m.Amortization
+	if a == nil {
+		return nil, ErrNoSchedule
+	} else if a.FirstPaymentDate.IsZero() || a.TotalYears <= 0 || a.PeriodsPerYear <= 0 || a.OriginalAmount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: missing first payment date, term, periods or original amount", ErrNoSchedule)
+	}
+
+	s := &Schedule{Payee: m.Payee, Account: m.ToAccount}
+	if s.Account == "" {
+		for _, split := range m.Split {
+			if split.Account != "" {
+				s.Account = split.Account
+				break
+			}
+		}
+	}
+
+	n := a.TotalYears * a.PeriodsPerYear
+	payment, err := level(a.OriginalAmount, a.InterestRate, a.PeriodsPerYear, n)
+	if err != nil {
+		return nil, err
+	}
+
+	periodsPerYear := stdlib.NewAmount(int64(100 * a.PeriodsPerYear))
+	balance := a.OriginalAmount
+	for i := 0; i < n && balance.Sign() > 0; i++ {
+		interest, err := balance.Mul(a.InterestRate).Div(periodsPerYear)
+		if err != nil {
+			return nil, err
+		}
+		interest = interest.Round(2)
+		principal := payment.Sub(interest)
+		if i == n-1 || principal.Cmp(balance) > 0 {
+			principal = balance
+		}
+		balance = balance.Sub(principal)
+		s.Payments = append(s.Payments, &Payment{
+			Number:    i + 1,
+			Date:      due(a.FirstPaymentDate, a.PeriodsPerYear, i),
+			Amount:    principal.Add(interest),
+			Principal: principal,
+			Interest:  interest,
+			Balance:   balance,
+		})
+	}
+
+	return s, nil
+}
+
+// level returns the payment, rounded to cents, that pays off a loan in n
+// equal payments.
+func level(amount, rate stdlib.Amount, perYear, n int) (stdlib.Amount, error) {
+	if rate.IsZero() {
+		payment, err := amount.Div(stdlib.NewAmount(int64(n)))
+		return payment.Round(2), err
+	}
+	// the formula needs a power, so this is the one place that uses
+	// floating point.
+	l, err := strconv.ParseFloat(amount.String(), 64)
+	if err != nil {
+		return stdlib.Amount{}, err
+	}
+	r, err := strconv.ParseFloat(rate.String(), 64)
+	if err != nil {
+		return stdlib.Amount{}, err
+	}
+	r = r / 100 / float64(perYear)
+	p := l * r / (1 - math.Pow(1+r, -float64(n)))
+	return stdlib.ParseAmount(strconv.FormatFloat(p, 'f', 2, 64))
+}
+
+// due returns the date of payment i, counting from zero. Schedules that
+// divide the year into whole months use calendar months. Weekly and
+// biweekly schedules use whole weeks. Anything else is spread evenly over
+// the days of the year.
+func due(first stdlib.CivilDate, perYear, i int) stdlib.CivilDate {
+	switch {
+	case 12%perYear == 0:
+		return first.AddMonths(i * 12 / perYear)
+	case perYear == 24: // twice a month, 15 days apart
+		date := first.AddMonths(i / 2)
+		if i%2 == 1 {
+			date = date.AddDays(15)
+		}
+		return date
+	case perYear == 26:
+		return first.AddDays(14 * i)
+	case perYear == 52:
+		return first.AddDays(7 * i)
+	}
+	return first.AddDays(i * 365 / perYear)
+}
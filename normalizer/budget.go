@@ -0,0 +1,162 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/reader/category"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+	"sort"
+	"time"
+)
+
+// Budget is the monthly budget for a category. Quicken exports one B
+// line per month, starting with January.
+type Budget struct {
+	Line     int
+	Category string
+	IsIncome bool
+	Months   [12]stdlib.Amount // January is Months[0]
+}
+
+// Total returns the budget for the year.
+func (b *Budget) Total() stdlib.Amount {
+	var total stdlib.Amount
+	for _, amount := range b.Months {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+// Budgets translates the B lines of the categories. Categories without
+// B lines aren't budgeted and are left out. Missing months are zero.
+//
+// Budgets returns an error if a category has more than twelve B lines
+// or an amount is invalid.
+func Budgets(categories []*category.Record) ([]*Budget, error) {
+	var budgets []*Budget
+	for _, c := range categories {
+		if len(c.BudgetAmount) == 0 {
+			continue
+		} else if len(c.BudgetAmount) > 12 {
+			return nil, fmt.Errorf("%d: category: found %d budget amounts", c.Line, len(c.BudgetAmount))
+		}
+		b := Budget{Line: c.Line, Category: c.Name, IsIncome: c.IsIncome}
+		for i, value := range c.BudgetAmount {
+			var err error
+			if b.Months[i], err = amount(value); err != nil {
+				return nil, fmt.Errorf("%d: category: budget: %w", c.Line, err)
+			}
+		}
+		budgets = append(budgets, &b)
+	}
+	return budgets, nil
+}
+
+// BudgetYear returns the year of the latest transaction, which is the
+// year that the budget is assumed to be for. It is the current year if
+// there aren't any transactions.
+func BudgetYear(transactions []*transaction.Record) int {
+	var latest stdlib.CivilDate
+	for _, t := range transactions {
+		if t.Date.After(latest) {
+			latest = t.Date
+		}
+	}
+	if latest.IsZero() {
+		return time.Now().Year()
+	}
+	return latest.Year
+}
+
+// Variance is the difference between the budget and the actual amount
+// for a category in a month.
+type Variance struct {
+	Category   string
+	Month      time.Month
+	Budget     stdlib.Amount
+	Actual     stdlib.Amount
+	Difference stdlib.Amount // actual minus budget
+}
+
+// BudgetVsActual totals the splits of the transactions in the year by
+// category and month and compares them with the budgets. Splits that
+// transfer to another account aren't spent against a category, so they
+// are ignored. Classes (the part of the category after a slash) are
+// ignored too.
+//
+// The variances are sorted by category and month. There is one for every
+// month of every category that has a budget or an actual amount.
+func BudgetVsActual(budgets []*Budget, transactions []*Transaction, year int) []*Variance {
+	months := make(map[string]*[12]Variance)
+	get := func(category string) *[12]Variance {
+		m, ok := months[category]
+		if !ok {
+			m = &[12]Variance{}
+			for i := range m {
+				m[i].Category, m[i].Month = category, time.Month(i+1)
+			}
+			months[category] = m
+		}
+		return m
+	}
+
+	for _, b := range budgets {
+		m := get(b.Category)
+		for i, amount := range b.Months {
+			m[i].Budget = m[i].Budget.Add(amount)
+		}
+	}
+	for _, t := range transactions {
+		if t.Date.Year != year {
+			continue
+		}
+		for _, split := range t.Split {
+			category := split.Parsed.Name()
+			if category == "" {
+				continue
+			}
+			m := get(category)
+			m[t.Date.Month-1].Actual = m[t.Date.Month-1].Actual.Add(split.Amount)
+		}
+	}
+
+	var categories []string
+	for category := range months {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var variances []*Variance
+	for _, category := range categories {
+		m := months[category]
+		for i := range m {
+			m[i].Difference = m[i].Actual.Sub(m[i].Budget)
+			variances = append(variances, &m[i])
+		}
+	}
+	return variances
+}
@@ -0,0 +1,122 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+	"time"
+)
+
+func TestBudgetVsActual(t *testing.T) {
+	// Specification: Budgets
+
+	// Given a budgeted category and transactions against it
+	input := `!Type:Cat
+NGroceries
+E
+B-300.00
+B-250.00
+^
+NSalary
+I
+^
+!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T-120.00
+LGroceries
+^
+D1/20'16
+T-100.00
+LGroceries/Home
+^
+D2/ 1'16
+T2,000.00
+LSalary
+^
+D2/ 2'16
+T-50.00
+L[Savings]
+^
+D1/ 4'15
+T-999.00
+LGroceries
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the budgets are read
+	// Then the B lines are mapped to months
+	budgets, err := normalizer.Budgets(r.Categories.Records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(budgets) != 1 || budgets[0].Category != "Groceries" {
+		t.Fatalf("budgets: yields %d: expected only Groceries\n", len(budgets))
+	}
+	if b := budgets[0]; b.Months[0].String() != "-300.00" || b.Months[1].String() != "-250.00" || !b.Months[2].IsZero() || b.Total().String() != "-550.00" {
+		t.Errorf("budgets: yields %v total %s\n", b.Months, b.Total())
+	}
+
+	// When they are compared with the transactions for 2016
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variances := normalizer.BudgetVsActual(budgets, transactions, 2016)
+
+	// Then each category has a variance for every month, without
+	// classes, transfers or other years
+	if len(variances) != 24 {
+		t.Fatalf("variances: yields %d: expected 24\n", len(variances))
+	}
+	jan := variances[0]
+	if jan.Category != "Groceries" || jan.Month != time.January || jan.Actual.String() != "-220.00" || jan.Difference.String() != "80.00" {
+		t.Errorf("january: yields %q %s actual %s difference %s\n", jan.Category, jan.Month, jan.Actual, jan.Difference)
+	}
+	feb := variances[12+1]
+	if feb.Category != "Salary" || feb.Month != time.February || feb.Actual.String() != "2000.00" || !feb.Budget.IsZero() {
+		t.Errorf("february: yields %q %s actual %s budget %s\n", feb.Category, feb.Month, feb.Actual, feb.Budget)
+	}
+}
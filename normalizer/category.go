@@ -0,0 +1,184 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/reader/category"
+	"sort"
+	"strings"
+)
+
+// CategoryRef is a category from an L or S line, split into its parts.
+// For example, `Auto:Fuel/Business` is the category Auto, the
+// subcategory Fuel and the class Business.
+type CategoryRef struct {
+	Category    string
+	Subcategory []string // the path below Category, if any
+	Class       string
+}
+
+// ParseCategory splits a category into its parts. Transfers (eg,
+// `[Checking]`) aren't categories and should not be passed in.
+func ParseCategory(s string) CategoryRef {
+	var ref CategoryRef
+	if n := strings.IndexByte(s, '/'); n != -1 {
+		s, ref.Class = s[:n], s[n+1:]
+	}
+	if s == "" {
+		return ref
+	}
+	path := strings.Split(s, ":")
+	ref.Category = path[0]
+	if len(path) > 1 {
+		ref.Subcategory = path[1:]
+	}
+	return ref
+}
+
+// Name returns the full name of the category without the class (eg,
+// `Auto:Fuel`).
+func (ref CategoryRef) Name() string {
+	if ref.Category == "" {
+		return ""
+	}
+	return strings.Join(append([]string{ref.Category}, ref.Subcategory...), ":")
+}
+
+// CategoryNode is a category in the tree.
+type CategoryNode struct {
+	Name     string           // the full name (eg, `Auto:Fuel`)
+	Label    string           // the last part of the name (eg, `Fuel`)
+	Record   *category.Record // nil if the category isn't in the category list
+	Parent   *CategoryNode
+	Children []*CategoryNode // sorted by label
+}
+
+// CategoryTree holds the categories from the category list and any
+// others that are used by transactions.
+type CategoryTree struct {
+	Roots []*CategoryNode // sorted by label
+	nodes map[string]*CategoryNode
+}
+
+// NewCategoryTree builds the tree from the category list and adds the
+// categories used by the splits of the transactions. Parents are added
+// for every subcategory, even when they aren't used on their own.
+func NewCategoryTree(categories []*category.Record, transactions []*Transaction) *CategoryTree {
+	tree := &CategoryTree{nodes: make(map[string]*CategoryNode)}
+	for _, c := range categories {
+		if node := tree.add(ParseCategory(c.Name)); node != nil {
+			node.Record = c
+		}
+	}
+	for _, t := range transactions {
+		for _, split := range t.Split {
+			tree.add(split.Parsed)
+		}
+	}
+	tree.sort(tree.Roots)
+	return tree
+}
+
+// Find returns the node for a category, or nil if the category isn't in
+// the tree. Classes are ignored.
+func (tree *CategoryTree) Find(name string) *CategoryNode {
+	return tree.nodes[ParseCategory(name).Name()]
+}
+
+// Walk calls fn for each category, parents before children. The depth of
+// a top level category is zero.
+func (tree *CategoryTree) Walk(fn func(node *CategoryNode, depth int)) {
+	var walk func(nodes []*CategoryNode, depth int)
+	walk = func(nodes []*CategoryNode, depth int) {
+		for _, node := range nodes {
+			fn(node, depth)
+			walk(node.Children, depth+1)
+		}
+	}
+	walk(tree.Roots, 0)
+}
+
+// add adds a category and its parents and returns the category's node.
+func (tree *CategoryTree) add(ref CategoryRef) *CategoryNode {
+	if ref.Category == "" {
+		return nil
+	}
+	var parent *CategoryNode
+	path := append([]string{ref.Category}, ref.Subcategory...)
+	for i, label := range path {
+		name := strings.Join(path[:i+1], ":")
+		node, ok := tree.nodes[name]
+		if !ok {
+			node = &CategoryNode{Name: name, Label: label, Parent: parent}
+			tree.nodes[name] = node
+			if parent == nil {
+				tree.Roots = append(tree.Roots, node)
+			} else {
+				parent.Children = append(parent.Children, node)
+			}
+		}
+		parent = node
+	}
+	return parent
+}
+
+func (tree *CategoryTree) sort(nodes []*CategoryNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Label < nodes[j].Label
+	})
+	for _, node := range nodes {
+		tree.sort(node.Children)
+	}
+}
+
+// UndefinedCategory is a split that uses a category that isn't in the
+// category list.
+type UndefinedCategory struct {
+	Line     int // the line of the split
+	Category string
+}
+
+func (u UndefinedCategory) String() string {
+	return fmt.Sprintf("%d: undefined category %q", u.Line, u.Category)
+}
+
+// UndefinedCategories returns the splits that use a category that isn't
+// defined in the category list. Classes are ignored.
+func UndefinedCategories(categories []*category.Record, transactions []*Transaction) []UndefinedCategory {
+	defined := make(map[string]bool)
+	for _, c := range categories {
+		defined[ParseCategory(c.Name).Name()] = true
+	}
+	var undefined []UndefinedCategory
+	for _, t := range transactions {
+		for _, split := range t.Split {
+			if name := split.Parsed.Name(); name != "" && !defined[name] {
+				undefined = append(undefined, UndefinedCategory{Line: split.Line, Category: name})
+			}
+		}
+	}
+	return undefined
+}
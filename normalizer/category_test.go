@@ -0,0 +1,137 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"reflect"
+	"testing"
+)
+
+func TestParseCategory(t *testing.T) {
+	// Specification: Category references
+
+	// When a category is parsed
+	// Then it is split into category, subcategory path and class
+	for _, tc := range []struct {
+		input    string
+		expected normalizer.CategoryRef
+	}{
+		{"Utilities", normalizer.CategoryRef{Category: "Utilities"}},
+		{"Auto:Fuel/Business", normalizer.CategoryRef{Category: "Auto", Subcategory: []string{"Fuel"}, Class: "Business"}},
+		{"Home:Repair:Roof", normalizer.CategoryRef{Category: "Home", Subcategory: []string{"Repair", "Roof"}}},
+		{"/Business", normalizer.CategoryRef{Class: "Business"}},
+	} {
+		if yields := normalizer.ParseCategory(tc.input); !reflect.DeepEqual(yields, tc.expected) {
+			t.Errorf("input of %q yields %+v: expected value is %+v\n", tc.input, yields, tc.expected)
+		}
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	// Specification: Category tree
+
+	// Given a category list and transactions that use other categories
+	input := `!Type:Cat
+NAuto
+E
+^
+NAuto:Fuel
+E
+^
+NSalary
+I
+^
+!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/20'16
+T-42.10
+LAuto:Fuel/Business
+^
+D1/21'16
+T-80.00
+LAuto:Repair
+^
+D1/22'16
+T-10.00
+SHome:Garden
+$-10.00
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the tree is built
+	tree := normalizer.NewCategoryTree(r.Categories.Records, transactions)
+
+	// Then it has every category in order, with parents before children
+	var yields []string
+	tree.Walk(func(node *normalizer.CategoryNode, depth int) {
+		yields = append(yields, node.Name)
+	})
+	expected := []string{"Auto", "Auto:Fuel", "Auto:Repair", "Home", "Home:Garden", "Salary"}
+	if !reflect.DeepEqual(yields, expected) {
+		t.Errorf("walk: yields %q: expected %q\n", yields, expected)
+	}
+	if node := tree.Find("Auto:Fuel/Business"); node == nil || node.Record == nil || node.Parent.Name != "Auto" {
+		t.Errorf("find: yields %+v: expected the defined Auto:Fuel\n", node)
+	}
+	if node := tree.Find("Auto:Repair"); node == nil || node.Record != nil {
+		t.Errorf("find: yields %+v: expected the undefined Auto:Repair\n", node)
+	}
+
+	// And the splits have the class parsed
+	if class := transactions[0].Split[0].Parsed.Class; class != "Business" {
+		t.Errorf("class: yields %q: expected %q\n", class, "Business")
+	}
+
+	// When the transactions are checked
+	// Then the undefined categories are flagged
+	undefined := normalizer.UndefinedCategories(r.Categories.Records, transactions)
+	if len(undefined) != 2 || undefined[0].Category != "Auto:Repair" || undefined[1].Category != "Home:Garden" {
+		t.Errorf("undefined: yields %v: expected Auto:Repair and Home:Garden\n", undefined)
+	}
+}
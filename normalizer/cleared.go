@@ -0,0 +1,98 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+	"strings"
+)
+
+// ErrUnknownClearedStatus is returned when the C line of a transaction
+// isn't one that Quicken writes.
+var ErrUnknownClearedStatus = errors.New("unknown cleared status")
+
+// ClearedStatus is the status from the C line of a transaction.
+type ClearedStatus int
+
+const (
+	Uncleared  ClearedStatus = iota // no C line
+	Cleared                         // `*` or `c`, checked off but not reconciled
+	Reconciled                      // `X` or `R`, reconciled with a statement
+)
+
+// ParseClearedStatus translates the C line of a transaction. The match
+// ignores case. An unknown status is returned as Uncleared along with
+// the error.
+func ParseClearedStatus(s string) (ClearedStatus, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return Uncleared, nil
+	case "*", "c":
+		return Cleared, nil
+	case "x", "r":
+		return Reconciled, nil
+	}
+	return Uncleared, fmt.Errorf("%w %q", ErrUnknownClearedStatus, s)
+}
+
+// UnknownClearedStatus is a transaction with a C line that Quicken
+// doesn't write. The transaction is treated as uncleared.
+type UnknownClearedStatus struct {
+	Line    int
+	Account string
+	Date    stdlib.CivilDate
+	Status  string
+}
+
+func (u UnknownClearedStatus) String() string {
+	return fmt.Sprintf("%d: unknown cleared status %q on %s in %q", u.Line, u.Status, u.Date, u.Account)
+}
+
+// UnknownClearedStatuses returns the records whose C line isn't one that
+// Quicken writes.
+func UnknownClearedStatuses(transactions []*transaction.Record) []UnknownClearedStatus {
+	var unknown []UnknownClearedStatus
+	for _, t := range transactions {
+		if _, err := ParseClearedStatus(t.ClearedStatus); err != nil {
+			unknown = append(unknown, UnknownClearedStatus{Line: t.Line, Account: t.Account, Date: t.Date, Status: t.ClearedStatus})
+		}
+	}
+	return unknown
+}
+
+func (c ClearedStatus) String() string {
+	switch c {
+	case Uncleared:
+		return "uncleared"
+	case Cleared:
+		return "cleared"
+	case Reconciled:
+		return "reconciled"
+	}
+	return fmt.Sprintf("ClearedStatus(%d)", int(c))
+}
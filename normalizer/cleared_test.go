@@ -0,0 +1,120 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"errors"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+)
+
+func TestClearedStatus(t *testing.T) {
+	// Specification: Cleared status
+
+	// Given the C lines that Quicken writes, in either case
+	for _, tc := range []struct {
+		input  string
+		expect normalizer.ClearedStatus
+	}{
+		{"", normalizer.Uncleared},
+		{"*", normalizer.Cleared},
+		{"c", normalizer.Cleared},
+		{"X", normalizer.Reconciled},
+		{"R", normalizer.Reconciled},
+		{"C", normalizer.Cleared},
+		{"x", normalizer.Reconciled},
+		{"r", normalizer.Reconciled},
+	} {
+		// When they are parsed
+		status, err := normalizer.ParseClearedStatus(tc.input)
+
+		// Then they map to the typed status
+		if err != nil {
+			t.Errorf("status %q: yields %v: expected nil\n", tc.input, err)
+		} else if status != tc.expect {
+			t.Errorf("status %q: yields %q: expected %q\n", tc.input, status, tc.expect)
+		}
+	}
+
+	// Given a C line that Quicken doesn't write
+	// When it is parsed
+	// Then it returns an error and treats the transaction as uncleared
+	if status, err := normalizer.ParseClearedStatus("?"); !errors.Is(err, normalizer.ErrUnknownClearedStatus) {
+		t.Errorf("status %q: yields %v: expected %v\n", "?", err, normalizer.ErrUnknownClearedStatus)
+	} else if status != normalizer.Uncleared {
+		t.Errorf("status %q: yields %q: expected %q\n", "?", status, normalizer.Uncleared)
+	}
+}
+
+func TestUnknownClearedStatuses(t *testing.T) {
+	// Specification: Unknown cleared statuses
+
+	// Given a reconciled transaction and one with a C line that Quicken
+	// doesn't write
+	input := `!Account
+NChecking
+TBank
+^
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+CX
+T-54.25
+^
+D1/ 5'16
+C?
+T-20.00
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are normalized
+	transactions, err := normalizer.Transactions(r.Transactions)
+
+	// Then the unknown status doesn't stop the import
+	if err != nil {
+		t.Fatalf("transactions: yields %v: expected nil\n", err)
+	} else if transactions[1].ClearedStatus != normalizer.Uncleared {
+		t.Errorf("transactions: yields %q: expected %q\n", transactions[1].ClearedStatus, normalizer.Uncleared)
+	}
+
+	// And the check reports it
+	unknown := normalizer.UnknownClearedStatuses(r.Transactions)
+	if len(unknown) != 1 || unknown[0].Line != 14 || unknown[0].Status != "?" {
+		t.Errorf("unknown: yields %v: expected line 14 %q\n", unknown, "?")
+	}
+}
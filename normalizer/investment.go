@@ -0,0 +1,328 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+	"strings"
+)
+
+// ErrUnknownAction is returned when an investment transaction has an
+// action that Quicken doesn't define.
+var ErrUnknownAction = errors.New("unknown action")
+
+// Action is the Quicken action from the N line of an investment
+// transaction. Actions that end with an X move cash to or from another
+// account instead of the investment account's cash balance.
+type Action string
+
+const (
+	Buy      Action = "Buy"
+	BuyX     Action = "BuyX"
+	CGLong   Action = "CGLong"
+	CGLongX  Action = "CGLongX"
+	CGMid    Action = "CGMid"
+	CGMidX   Action = "CGMidX"
+	CGShort  Action = "CGShort"
+	CGShortX Action = "CGShortX"
+	Cash     Action = "Cash"
+	ContribX Action = "ContribX"
+	CvrShrt  Action = "CvrShrt"
+	Div      Action = "Div"
+	DivX     Action = "DivX"
+	Exercise Action = "Exercise"
+	ExercisX Action = "ExercisX"
+	Expire   Action = "Expire"
+	Grant    Action = "Grant"
+	IntInc   Action = "IntInc"
+	IntIncX  Action = "IntIncX"
+	MargInt  Action = "MargInt"
+	MargIntX Action = "MargIntX"
+	MiscExp  Action = "MiscExp"
+	MiscExpX Action = "MiscExpX"
+	MiscInc  Action = "MiscInc"
+	MiscIncX Action = "MiscIncX"
+	ReinvDiv Action = "ReinvDiv"
+	ReinvInt Action = "ReinvInt"
+	ReinvLg  Action = "ReinvLg"
+	ReinvMd  Action = "ReinvMd"
+	ReinvSh  Action = "ReinvSh"
+	Reminder Action = "Reminder"
+	Reprice  Action = "Reprice"
+	RtrnCap  Action = "RtrnCap"
+	RtrnCapX Action = "RtrnCapX"
+	Sell     Action = "Sell"
+	SellX    Action = "SellX"
+	ShrsIn   Action = "ShrsIn"
+	ShrsOut  Action = "ShrsOut"
+	ShtSell  Action = "ShtSell"
+	StkSplit Action = "StkSplit"
+	Vest     Action = "Vest"
+	WithdrwX Action = "WithdrwX"
+	XIn      Action = "XIn"
+	XOut     Action = "XOut"
+)
+
+// actions maps the lower case name of each action to the action.
+var actions = func() map[string]Action {
+	m := make(map[string]Action)
+	for _, a := range []Action{
+		Buy, BuyX, CGLong, CGLongX, CGMid, CGMidX, CGShort, CGShortX, Cash, ContribX, CvrShrt,
+		Div, DivX, Exercise, ExercisX, Expire, Grant, IntInc, IntIncX, MargInt, MargIntX,
+		MiscExp, MiscExpX, MiscInc, MiscIncX, ReinvDiv, ReinvInt, ReinvLg, ReinvMd, ReinvSh,
+		Reminder, Reprice, RtrnCap, RtrnCapX, Sell, SellX, ShrsIn, ShrsOut, ShtSell, StkSplit,
+		Vest, WithdrwX, XIn, XOut,
+	} {
+		m[strings.ToLower(string(a))] = a
+	}
+	return m
+}()
+
+// ParseAction translates the N line of an investment transaction to an
+// Action. The match ignores case. A transaction without an action only
+// moves cash, so it is a Cash action.
+func ParseAction(s string) (Action, error) {
+	if strings.TrimSpace(s) == "" {
+		return Cash, nil
+	} else if a, ok := actions[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return a, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownAction, s)
+}
+
+// IsBuy returns true if the action adds shares that are paid for with
+// cash (eg, Buy or ReinvDiv).
+func (a Action) IsBuy() bool {
+	switch a {
+	case Buy, BuyX, CvrShrt, ReinvDiv, ReinvInt, ReinvLg, ReinvMd, ReinvSh:
+		return true
+	}
+	return false
+}
+
+// IsSell returns true if the action removes shares in exchange for cash.
+func (a Action) IsSell() bool {
+	switch a {
+	case Sell, SellX, ShtSell:
+		return true
+	}
+	return false
+}
+
+// IsTransfer returns true if the action moves cash to or from another
+// account.
+func (a Action) IsTransfer() bool {
+	return strings.HasSuffix(string(a), "X") || a == XIn || a == XOut
+}
+
+// IsCashIn returns true if a transfer action moves cash from the other
+// account into the investment account (eg, BuyX, XIn or MargIntX).
+// Otherwise, the cash goes to the other account.
+func (a Action) IsCashIn() bool {
+	switch a {
+	case BuyX, ContribX, MargIntX, MiscExpX, XIn:
+		return true
+	}
+	return false
+}
+
+// Investment is a transaction from an investment account.
+type Investment struct {
+	Line          int
+	Account       string
+	Action        Action
+	Category      string
+	ClearedStatus ClearedStatus
+	Commission    stdlib.Amount
+	Date          stdlib.CivilDate
+	Memo          string
+	Payee         string
+	Price         stdlib.Amount
+	Quantity      stdlib.Amount
+	Security      string
+	Total         stdlib.Amount // the T amount
+	Transfer      *Transfer     // the cash leg, if there is one
+}
+
+// Transfer is the cash leg of an investment transaction. It comes from
+// the L[account] line and the $ amount.
+type Transfer struct {
+	Account string
+	Amount  stdlib.Amount
+}
+
+// Investments translates the records from investment accounts. Records
+// from other types of accounts are ignored.
+//
+// Actions that Quicken doesn't define and buys and sells whose amounts
+// don't add up are kept as they are so that one bad record doesn't stop
+// the import. UnknownActions and AmountMismatches report them.
+//
+// Investments returns an error if an amount is invalid.
+func Investments(transactions []*transaction.Record) ([]*Investment, error) {
+	var normalized []*Investment
+	for _, t := range transactions {
+		if t.Type != "Invst" {
+			continue
+		}
+		action, err := ParseAction(t.RefNo)
+		if err != nil {
+			action = Action(strings.TrimSpace(t.RefNo))
+		}
+		// an unknown status is reported by UnknownClearedStatuses
+		cleared, _ := ParseClearedStatus(t.ClearedStatus)
+		inv := Investment{
+			Line:          t.Line,
+			Account:       t.Account,
+			Action:        action,
+			Category:      t.Category,
+			ClearedStatus: cleared,
+			Date:          t.Date,
+			Memo:          t.Memo,
+			Payee:         t.Payee,
+			Security:      t.Ticker,
+		}
+		for _, field := range []struct {
+			name  string
+			value string
+			dst   *stdlib.Amount
+		}{
+			{"commission", t.Commission, &inv.Commission},
+			{"price", t.Interest, &inv.Price},
+			{"quantity", t.Quantity, &inv.Quantity},
+			{"amount", t.AmountTCode, &inv.Total},
+		} {
+			if *field.dst, err = amount(field.value); err != nil {
+				return nil, fmt.Errorf("%d: investment: %s: %w", t.Line, field.name, err)
+			}
+		}
+
+		if t.ToAccount != "" {
+			inv.Transfer = &Transfer{Account: t.ToAccount, Amount: inv.Total}
+			if len(t.Split) != 0 && t.Split[0].Amount != "" {
+				if inv.Transfer.Amount, err = amount(t.Split[0].Amount); err != nil {
+					return nil, fmt.Errorf("%d: investment: transfer: %w", t.Line, err)
+				}
+			}
+		}
+
+		normalized = append(normalized, &inv)
+	}
+	return normalized, nil
+}
+
+// UnknownAction is an investment transaction with an action that Quicken
+// doesn't define.
+type UnknownAction struct {
+	Line    int
+	Account string
+	Date    stdlib.CivilDate
+	Action  string
+}
+
+func (u UnknownAction) String() string {
+	return fmt.Sprintf("%d: unknown action %q on %s in %q", u.Line, u.Action, u.Date, u.Account)
+}
+
+// UnknownActions returns the investment transactions whose action isn't
+// one that Quicken defines. They are ignored when translating.
+func UnknownActions(transactions []*Transaction) []UnknownAction {
+	var unknown []UnknownAction
+	for _, t := range transactions {
+		if t.Type != "Invst" {
+			continue
+		}
+		if _, err := ParseAction(t.RefNo); err != nil {
+			unknown = append(unknown, UnknownAction{Line: t.Line, Account: t.Account, Date: t.Date, Action: t.RefNo})
+		}
+	}
+	return unknown
+}
+
+// Mismatch is a buy or sell whose amount doesn't equal the quantity
+// times the price adjusted by the commission.
+type Mismatch struct {
+	Line       int
+	Account    string
+	Date       stdlib.CivilDate
+	Action     Action
+	Quantity   stdlib.Amount
+	Price      stdlib.Amount
+	Commission stdlib.Amount
+	Expected   stdlib.Amount // the quantity times the price adjusted by the commission
+	Total      stdlib.Amount // the T amount
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%d: amount mismatch on %s in %q: %s * %s %s %s is %s, not %s", m.Line, m.Date, m.Account,
+		m.Quantity, m.Price, commissionSign(m.Action), m.Commission, m.Expected, m.Total)
+}
+
+// AmountMismatches returns the buys and sells whose amounts don't add
+// up. The product of the quantity and price is rounded to cents and may
+// be off by one cent, since Quicken rounds the price that it exports.
+// Transactions without a quantity, price or amount are skipped.
+func AmountMismatches(investments []*Investment) []Mismatch {
+	var mismatches []Mismatch
+	for _, inv := range investments {
+		if inv.Quantity.IsZero() || inv.Price.IsZero() || inv.Total.IsZero() {
+			continue
+		}
+		expected := inv.Quantity.Mul(inv.Price).Round(2)
+		switch {
+		case inv.Action.IsBuy():
+			expected = expected.Add(inv.Commission)
+		case inv.Action.IsSell():
+			expected = expected.Sub(inv.Commission)
+		default:
+			continue
+		}
+		if !expected.WithinCent(inv.Total) {
+			mismatches = append(mismatches, Mismatch{
+				Line:       inv.Line,
+				Account:    inv.Account,
+				Date:       inv.Date,
+				Action:     inv.Action,
+				Quantity:   inv.Quantity,
+				Price:      inv.Price,
+				Commission: inv.Commission,
+				Expected:   expected,
+				Total:      inv.Total,
+			})
+		}
+	}
+	return mismatches
+}
+
+// commissionSign returns the operator used to apply the commission.
+func commissionSign(a Action) string {
+	if a.IsSell() {
+		return "-"
+	}
+	return "+"
+}
@@ -0,0 +1,231 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"errors"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+)
+
+// readInvestments returns the investment transactions from a brokerage
+// account named Brokerage.
+func readInvestments(t *testing.T, records string) ([]*normalizer.Investment, error) {
+	return normalizer.Investments(readBrokerage(t, records).Transactions)
+}
+
+// readBrokerage reads the records of a brokerage account named Brokerage.
+func readBrokerage(t *testing.T, records string) *reader.Reader {
+	input := `!Option:AutoSwitch
+!Account
+NBrokerage
+TInvst
+^
+NChecking
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NBrokerage
+TInvst
+^
+!Type:Invst
+` + records
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestInvestments(t *testing.T) {
+	// Specification: Investment transactions
+
+	// Given investment transactions with cash transfers
+	// When they are normalized
+	// Then the actions, amounts and transfers are set
+	investments, err := readInvestments(t, `D1/ 4'16
+NBuyX
+YAcme Widgets
+I12.345
+Q100
+O9.95
+T1244.45
+L[Checking]
+$1244.45
+^
+D2/ 1'16
+Nsell
+YAcme Widgets
+I13.50
+Q50
+O9.95
+T665.05
+^
+D3/ 1'16
+NDiv
+YAcme Widgets
+T25.00
+LDividend Income
+^
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(investments) != 3 {
+		t.Fatalf("investments: yields %d: expected 3\n", len(investments))
+	}
+	buy, sell, div := investments[0], investments[1], investments[2]
+	if buy.Action != normalizer.BuyX || !buy.Action.IsBuy() || !buy.Action.IsTransfer() {
+		t.Errorf("buy: action yields %q: expected %q\n", buy.Action, normalizer.BuyX)
+	}
+	if buy.Security != "Acme Widgets" || buy.Quantity.String() != "100.00" || buy.Price.String() != "12.345" || buy.Commission.String() != "9.95" {
+		t.Errorf("buy: yields %q %s @ %s + %s\n", buy.Security, buy.Quantity, buy.Price, buy.Commission)
+	}
+	if buy.Transfer == nil || buy.Transfer.Account != "Checking" || buy.Transfer.Amount.String() != "1244.45" {
+		t.Errorf("buy: transfer yields %+v: expected Checking 1244.45\n", buy.Transfer)
+	}
+	if sell.Action != normalizer.Sell || sell.Transfer != nil {
+		t.Errorf("sell: yields %q %+v: expected %q with no transfer\n", sell.Action, sell.Transfer, normalizer.Sell)
+	}
+	if div.Action != normalizer.Div || div.Category != "Dividend Income" || div.Total.String() != "25.00" {
+		t.Errorf("div: yields %q %q %s: expected %q %q %s\n", div.Action, div.Category, div.Total, normalizer.Div, "Dividend Income", "25.00")
+	}
+
+	// Given a buy where the amounts don't add up and one that is off by
+	// a cent
+	// When they are normalized
+	// Then both are kept
+	investments, err = readInvestments(t, `D1/ 4'16
+NBuy
+YAcme Widgets
+I12.00
+Q100
+O9.95
+T1300.00
+^
+D1/ 5'16
+NBuy
+YAcme Widgets
+I12.00
+Q100
+O9.95
+T1209.96
+^
+`)
+	if err != nil {
+		t.Fatalf("mismatch: yields %v: expected nil\n", err)
+	} else if len(investments) != 2 {
+		t.Fatalf("mismatch: yields %d: expected 2\n", len(investments))
+	}
+
+	// And only the first is reported as a mismatch
+	mismatches := normalizer.AmountMismatches(investments)
+	if len(mismatches) != 1 {
+		t.Fatalf("mismatch: yields %v: expected 1\n", mismatches)
+	}
+	if m := mismatches[0]; m.Line != 15 || m.Expected.String() != "1209.95" || m.Total.String() != "1300.00" {
+		t.Errorf("mismatch: yields %s: expected line 15 1209.95, not 1300.00\n", m)
+	}
+
+	// Given a Cash action, a transaction without an action and an
+	// unknown action
+	// When they are normalized
+	// Then the first two are Cash actions and the unknown action is kept
+	investments, err = readInvestments(t, `D1/ 4'16
+NCash
+T-25.00
+L[Checking]
+^
+D1/ 5'16
+T10.00
+LInterest
+^
+D1/ 6'16
+NBuyAndHold
+T100.00
+^
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(investments) != 3 {
+		t.Fatalf("actions: yields %d: expected 3\n", len(investments))
+	}
+	if cash := investments[0]; cash.Action != normalizer.Cash || cash.Transfer == nil || cash.Transfer.Account != "Checking" {
+		t.Errorf("cash: yields %q %+v: expected %q to Checking\n", cash.Action, cash.Transfer, normalizer.Cash)
+	}
+	if empty := investments[1]; empty.Action != normalizer.Cash || empty.Category != "Interest" {
+		t.Errorf("empty: yields %q %q: expected %q %q\n", empty.Action, empty.Category, normalizer.Cash, "Interest")
+	}
+	if unknown := investments[2]; unknown.Action != "BuyAndHold" {
+		t.Errorf("unknown: yields %q: expected %q\n", unknown.Action, "BuyAndHold")
+	}
+}
+
+func TestUnknownActions(t *testing.T) {
+	// Specification: Unknown investment actions
+
+	// Given a known action, an empty action and an unknown action
+	r := readBrokerage(t, `D1/ 4'16
+NMiscIncX
+T10.00
+L[Checking]
+^
+D1/ 5'16
+T10.00
+^
+D1/ 6'16
+NBuyAndHold
+T100.00
+^
+`)
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are checked
+	unknown := normalizer.UnknownActions(transactions)
+
+	// Then only the unknown action is reported
+	if len(unknown) != 1 {
+		t.Fatalf("unknown: yields %v: expected 1\n", unknown)
+	}
+	if unknown[0].Line != 23 || unknown[0].Action != "BuyAndHold" || unknown[0].Account != "Brokerage" {
+		t.Errorf("unknown: yields %s: expected line 23 %q in %q\n", unknown[0], "BuyAndHold", "Brokerage")
+	}
+
+	// And ParseAction returns an error for it
+	if _, err := normalizer.ParseAction("BuyAndHold"); !errors.Is(err, normalizer.ErrUnknownAction) {
+		t.Errorf("parse: yields %v: expected %v\n", err, normalizer.ErrUnknownAction)
+	}
+}
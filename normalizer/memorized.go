@@ -0,0 +1,193 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+	"strconv"
+	"strings"
+)
+
+// MemorizedType is the kind of transaction that a memorized payee
+// creates. It comes from the K line.
+type MemorizedType string
+
+const (
+	MemorizedCheck      MemorizedType = "check"      // KC
+	MemorizedDeposit    MemorizedType = "deposit"    // KD
+	MemorizedElectronic MemorizedType = "electronic" // KE
+	MemorizedInvestment MemorizedType = "investment" // KI
+	MemorizedPayment    MemorizedType = "payment"    // KP
+)
+
+// ErrUnknownMemorizedType is returned when the K line of a memorized
+// payee isn't one that Quicken writes.
+var ErrUnknownMemorizedType = errors.New("unknown memorized type")
+
+// ParseMemorizedType translates the K line of a memorized payee. An
+// unknown type is returned as "" along with the error.
+func ParseMemorizedType(s string) (MemorizedType, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "C":
+		return MemorizedCheck, nil
+	case "D":
+		return MemorizedDeposit, nil
+	case "E":
+		return MemorizedElectronic, nil
+	case "I":
+		return MemorizedInvestment, nil
+	case "P":
+		return MemorizedPayment, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownMemorizedType, s)
+}
+
+// UnknownMemorizedType is a memorized payee with a K line that Quicken
+// doesn't write.
+type UnknownMemorizedType struct {
+	Line  int
+	Payee string
+	Flag  string
+}
+
+func (u UnknownMemorizedType) String() string {
+	return fmt.Sprintf("%d: unknown memorized type %q for %q", u.Line, u.Flag, u.Payee)
+}
+
+// UnknownMemorizedTypes returns the memorized payees whose K line isn't
+// one that Quicken writes.
+func UnknownMemorizedTypes(records []*transaction.Record) []UnknownMemorizedType {
+	var unknown []UnknownMemorizedType
+	for _, t := range records {
+		if _, err := ParseMemorizedType(t.MemorizedFlag); err != nil {
+			unknown = append(unknown, UnknownMemorizedType{Line: t.Line, Payee: t.Payee, Flag: t.MemorizedFlag})
+		}
+	}
+	return unknown
+}
+
+// MemorizedPayee is a transaction that Quicken fills in when the payee
+// is entered.
+type MemorizedPayee struct {
+	Line          int
+	Type          MemorizedType // "" if the K line is unknown
+	Flag          string        // the K line as it was read
+	Address       []string
+	Amortization  *Amortization // nil unless the payee pays off a loan
+	Category      string
+	ClearedStatus ClearedStatus
+	Memo          string
+	Payee         string
+	Split         []*Split
+	ToAccount     string
+	Total         stdlib.Amount // the T amount
+}
+
+// Amortization is the loan schedule from lines 1 through 7 of a
+// memorized payee.
+type Amortization struct {
+	FirstPaymentDate stdlib.CivilDate // line 1
+	TotalYears       int              // line 2
+	PaymentsMade     int              // line 3, the number of payments already made
+	PeriodsPerYear   int              // line 4
+	InterestRate     stdlib.Amount    // line 5, as a percent
+	CurrentBalance   stdlib.Amount    // line 6
+	OriginalAmount   stdlib.Amount    // line 7
+}
+
+// MemorizedPayees translates the records from the Memorized section.
+// The first payment date is parsed with the same date format as the rest
+// of the input.
+func MemorizedPayees(records []*transaction.Record, dates stdlib.DateOptions) ([]*MemorizedPayee, error) {
+	var normalized []*MemorizedPayee
+	for _, t := range records {
+		// an unknown type is reported by UnknownMemorizedTypes
+		typ, _ := ParseMemorizedType(t.MemorizedFlag)
+		total, err := amount(t.AmountTCode)
+		if err != nil {
+			return nil, fmt.Errorf("%d: memorized: %w", t.Line, err)
+		}
+		// an unknown status is reported by UnknownClearedStatuses
+		cleared, _ := ParseClearedStatus(t.ClearedStatus)
+		m := MemorizedPayee{
+			Line:          t.Line,
+			Type:          typ,
+			Flag:          t.MemorizedFlag,
+			Address:       t.Address,
+			Category:      t.Category,
+			ClearedStatus: cleared,
+			Memo:          t.Memo,
+			Payee:         t.Payee,
+			ToAccount:     t.ToAccount,
+			Total:         total,
+		}
+		if m.Split, err = splits(t, total); err != nil {
+			return nil, err
+		}
+		if len(t.BudgetAmount) != 0 {
+			if m.Amortization, err = amortization(t.BudgetAmount, dates); err != nil {
+				return nil, fmt.Errorf("%d: memorized: %w", t.Line, err)
+			}
+		}
+		normalized = append(normalized, &m)
+	}
+	return normalized, nil
+}
+
+// amortization translates lines 1 through 7 of a memorized payee.
+// Missing lines are left as zero.
+func amortization(lines []string, dates stdlib.DateOptions) (*Amortization, error) {
+	var a Amortization
+	var err error
+	for i, value := range lines {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		switch i + 1 {
+		case 1:
+			a.FirstPaymentDate, err = dates.Parse(value)
+		case 2:
+			a.TotalYears, err = strconv.Atoi(value)
+		case 3:
+			a.PaymentsMade, err = strconv.Atoi(value)
+		case 4:
+			a.PeriodsPerYear, err = strconv.Atoi(value)
+		case 5:
+			a.InterestRate, err = stdlib.ParseAmount(value)
+		case 6:
+			a.CurrentBalance, err = stdlib.ParseAmount(value)
+		case 7:
+			a.OriginalAmount, err = stdlib.ParseAmount(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("amortization line %d: %w", i+1, err)
+		}
+	}
+	return &a, nil
+}
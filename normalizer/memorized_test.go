@@ -0,0 +1,151 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+	"time"
+)
+
+func TestMemorizedPayees(t *testing.T) {
+	// Specification: Memorized payees
+
+	// Given a memorized loan payment and a memorized deposit without
+	// some of the amortization lines
+	input := `!Type:Memorized
+KP
+T-1,200.00
+PFirst Mortgage Co
+L[Mortgage]
+1 1/ 1'16
+230
+3360
+412
+53.75
+6250,000.00
+7250,000.00
+^
+KD
+T500.00
+PPaycheck
+LSalary
+2 5
+7 1,000.00
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are normalized
+	memorized, err := normalizer.MemorizedPayees(r.Memorized, r.Dates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memorized) != 2 {
+		t.Fatalf("memorized: yields %d: expected 2\n", len(memorized))
+	}
+
+	// Then the type and amortization fields are decoded
+	loan := memorized[0]
+	if loan.Type != normalizer.MemorizedPayment || loan.Payee != "First Mortgage Co" || loan.Total.String() != "-1200.00" {
+		t.Errorf("loan: yields %q %q %s\n", loan.Type, loan.Payee, loan.Total)
+	}
+	if loan.Amortization == nil {
+		t.Fatalf("loan: amortization yields nil\n")
+	}
+	a := loan.Amortization
+	if a.FirstPaymentDate.Year != 2016 || a.FirstPaymentDate.Month != time.January || a.FirstPaymentDate.Day != 1 {
+		t.Errorf("loan: first payment yields %s: expected 2016-01-01\n", a.FirstPaymentDate)
+	}
+	if a.TotalYears != 30 || a.PaymentsMade != 360 || a.PeriodsPerYear != 12 {
+		t.Errorf("loan: yields %d years %d payments %d periods: expected 30, 360, 12\n", a.TotalYears, a.PaymentsMade, a.PeriodsPerYear)
+	}
+	if a.InterestRate.String() != "3.75" || a.CurrentBalance.String() != "250000.00" || a.OriginalAmount.String() != "250000.00" {
+		t.Errorf("loan: yields rate %s balance %s original %s\n", a.InterestRate, a.CurrentBalance, a.OriginalAmount)
+	}
+
+	// And missing amortization lines don't shift the ones that follow
+	deposit := memorized[1]
+	if deposit.Type != normalizer.MemorizedDeposit || deposit.Amortization == nil {
+		t.Fatalf("deposit: yields %q %+v\n", deposit.Type, deposit.Amortization)
+	}
+	if deposit.Amortization.TotalYears != 5 || deposit.Amortization.PeriodsPerYear != 0 || deposit.Amortization.OriginalAmount.String() != "1000.00" {
+		t.Errorf("deposit: yields %+v: expected 5 years and 1000.00 original\n", *deposit.Amortization)
+	}
+}
+
+func TestUnknownMemorizedTypes(t *testing.T) {
+	// Specification: Unknown memorized types
+
+	// Given a memorized payee with a K line that Quicken doesn't write
+	input := `!Type:Memorized
+KC
+T-54.25
+PGrocer
+^
+KZ
+T-20.00
+PDiner
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are normalized
+	memorized, err := normalizer.MemorizedPayees(r.Memorized, r.Dates)
+
+	// Then the unknown type doesn't stop the section
+	if err != nil {
+		t.Fatalf("memorized: yields %v: expected nil\n", err)
+	} else if len(memorized) != 2 {
+		t.Fatalf("memorized: yields %d: expected 2\n", len(memorized))
+	}
+
+	// And the payee keeps the flag as it was read
+	if diner := memorized[1]; diner.Type != "" || diner.Flag != "Z" {
+		t.Errorf("diner: yields %q %q: expected %q %q\n", diner.Type, diner.Flag, "", "Z")
+	}
+
+	// And the check reports it
+	unknown := normalizer.UnknownMemorizedTypes(r.Memorized)
+	if len(unknown) != 1 || unknown[0].Line != 6 || unknown[0].Flag != "Z" || unknown[0].Payee != "Diner" {
+		t.Errorf("unknown: yields %v: expected line 6 %q for %q\n", unknown, "Z", "Diner")
+	}
+}
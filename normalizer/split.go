@@ -0,0 +1,108 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/stdlib"
+)
+
+// SplitProblem is the kind of problem with the lines of a transaction.
+type SplitProblem string
+
+const (
+	SplitMismatch     SplitProblem = "splits don't add up to the total"
+	OrphanSplit       SplitProblem = "memo or amount without a category line"
+	EmptyCategory     SplitProblem = "split without a category"
+	DuplicateCategory SplitProblem = "duplicate split category"
+)
+
+// Uncategorized is the category of the line that balances a split
+// transaction.
+const Uncategorized = "Uncategorized"
+
+// SplitIssue is a problem with the lines of a transaction.
+type SplitIssue struct {
+	Line    int // the line of the transaction
+	Problem SplitProblem
+	Detail  string
+}
+
+func (s SplitIssue) String() string {
+	return fmt.Sprintf("%d: split: %s: %s", s.Line, s.Problem, s.Detail)
+}
+
+// SplitOptions controls how split transactions are validated.
+type SplitOptions struct {
+	// Balance adds an Uncategorized line to transactions whose lines
+	// don't add up to the total.
+	Balance bool
+}
+
+// ValidateSplits checks that the lines of each split transaction add up
+// to the total exactly. It also reports lines that were started by an E
+// or $ line instead of an S line, lines without a category or transfer
+// account, and categories that are used by more than one line. A first
+// line that only has the transfer account of the L line counts as
+// having no category.
+// Transactions without split lines are skipped, and so are investment
+// transactions since their $ line is the cash leg.
+//
+// Issues are in the order of the transactions.
+func ValidateSplits(transactions []*Transaction, opts SplitOptions) []SplitIssue {
+	var issues []SplitIssue
+	for _, t := range transactions {
+		if t.Type == "Invst" || !t.IsSplit {
+			continue
+		}
+		var sum stdlib.Amount
+		seen := make(map[string]bool)
+		for _, split := range t.Split {
+			sum = sum.Add(split.Amount)
+			switch {
+			case split.IsImplied:
+				issues = append(issues, SplitIssue{Line: t.Line, Problem: OrphanSplit, Detail: fmt.Sprintf("line %d", split.Line)})
+			case split.Category == "" && (split.Account == "" || split.IsInherited):
+				issues = append(issues, SplitIssue{Line: t.Line, Problem: EmptyCategory, Detail: fmt.Sprintf("line %d", split.Line)})
+			case split.Category != "" && seen[split.Category]:
+				issues = append(issues, SplitIssue{Line: t.Line, Problem: DuplicateCategory, Detail: fmt.Sprintf("line %d: %q", split.Line, split.Category)})
+			}
+			seen[split.Category] = true
+		}
+		if difference := t.Total.Sub(sum); !difference.IsZero() {
+			issues = append(issues, SplitIssue{Line: t.Line, Problem: SplitMismatch, Detail: fmt.Sprintf("total %s, splits %s, off by %s", t.Total, sum, difference)})
+			if opts.Balance {
+				t.Split = append(t.Split, &Split{
+					Line:     t.Line,
+					Amount:   difference,
+					Category: Uncategorized,
+					Parsed:   ParseCategory(Uncategorized),
+				})
+				t.IsZero, t.IsLinked = false, false
+			}
+		}
+	}
+	return issues
+}
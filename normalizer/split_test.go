@@ -0,0 +1,166 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+)
+
+func TestValidateSplits(t *testing.T) {
+	// Specification: Split validation
+
+	// Given a balanced split, a split that is off by a cent, a memo
+	// without a category line, a split without a category and a
+	// category that is used twice, repeated amount and memo lines that
+	// start lines of their own, and an empty first split in a transaction
+	// with a transfer account
+	input := `!Account
+NChecking
+TBank
+^
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T-75.00
+PGrocer
+SGroceries
+$-50.00
+SDining
+$-25.00
+^
+D1/ 5'16
+T-75.00
+PGrocer
+SGroceries
+$-50.00
+SDining
+$-24.99
+^
+D1/ 6'16
+T-10.00
+PDiner
+EFood
+$-10.00
+^
+D1/ 7'16
+T-10.00
+PDiner
+S
+$-10.00
+^
+D1/ 8'16
+T-10.00
+PDiner
+SDining
+$-4.00
+SDining
+$-6.00
+^
+D1/ 9'16
+T-10.00
+PDiner
+LDining
+^
+D1/10'16
+T-10.00
+PDiner
+SDining
+$-4.00
+$-6.00
+^
+D1/11'16
+T-10.00
+PDiner
+SDining
+EFood
+EDrinks
+$-10.00
+^
+D1/12'16
+T-10.00
+PDiner
+L[Savings]
+S
+$-10.00
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are validated
+	issues := normalizer.ValidateSplits(transactions, normalizer.SplitOptions{})
+
+	// Then each problem is reported with the line of the transaction
+	expected := []struct {
+		line    int
+		problem normalizer.SplitProblem
+	}{
+		{18, normalizer.SplitMismatch},
+		{26, normalizer.OrphanSplit},
+		{32, normalizer.EmptyCategory},
+		{38, normalizer.DuplicateCategory},
+		{51, normalizer.OrphanSplit},
+		{58, normalizer.OrphanSplit},
+		{66, normalizer.EmptyCategory},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("issues: yields %v: expected %d\n", issues, len(expected))
+	}
+	for i, e := range expected {
+		if issues[i].Line != e.line || issues[i].Problem != e.problem {
+			t.Errorf("issue %d: yields %s: expected %d: %s\n", i, issues[i], e.line, e.problem)
+		}
+	}
+
+	// When they are validated with balancing
+	issues = normalizer.ValidateSplits(transactions, normalizer.SplitOptions{Balance: true})
+
+	// Then the mismatched split gets an Uncategorized line for the difference
+	split := transactions[1].Split[len(transactions[1].Split)-1]
+	if len(transactions[1].Split) != 3 || split.Category != normalizer.Uncategorized || split.Amount.String() != "-0.01" {
+		t.Errorf("balance: yields %+v: expected an Uncategorized line for -0.01\n", split)
+	}
+
+	// And it adds up when it is validated again
+	if issues = normalizer.ValidateSplits(transactions[1:2], normalizer.SplitOptions{}); len(issues) != 0 {
+		t.Errorf("balance: yields %v: expected no issues\n", issues)
+	}
+}
@@ -24,7 +24,11 @@
 
 package normalizer
 
-import "github.com/maloquacious/qif/reader/transaction"
+import (
+	"fmt"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+)
 
 type Transaction struct {
 	Line          int
@@ -32,11 +36,12 @@ type Transaction struct {
 	Account       string
 	Address       []string // Up to five lines (the sixth line is an optional message)
 	Category      string
-	ClearedStatus string
+	ClearedStatus ClearedStatus
 	Commission    string
-	Date          string
+	Date          stdlib.CivilDate
 	Interest      string
-	IsLinked      bool
+	IsLinked      bool // every line is a transfer that the other account records
+	IsSplit       bool // the lines came from S, E and $ lines
 	IsZero        bool
 	Memo          string
 	MemorizedFlag string
@@ -46,77 +51,126 @@ type Transaction struct {
 	RefNo         string
 	Split         []*Split
 	Ticker        string
+	Total         stdlib.Amount // the T amount
 }
 
 type Split struct {
-	Line     int
-	Account  string
-	Amount   string
-	Category string
-	IsZero   bool
-	Memo     string
-	Ticker   string
+	Line        int
+	Account     string
+	Amount      stdlib.Amount
+	Category    string
+	IsImplied   bool // the line didn't start with an S line
+	IsInherited bool // the transfer account came from the L line
+	IsLinked    bool // the transfer is recorded by the matching line
+	IsZero      bool
+	Memo        string
+	Parsed      CategoryRef // Category split into its parts
+	Ticker      string
+	Transfer    *Split // the other leg of a transfer, if it was found
 }
 
-func Transactions(transactions []*transaction.Record) []*Transaction {
+// Options controls how transactions are normalized.
+type Options struct {
+	// TransferWindow is the number of days that the two sides of a
+	// transfer can differ by.
+	TransferWindow int
+}
+
+// DefaultOptions allows for the few days that a transfer between banks
+// takes to post.
+var DefaultOptions = Options{TransferWindow: 3}
+
+// Transactions returns an error if any of the amounts are invalid.
+func Transactions(transactions []*transaction.Record) ([]*Transaction, error) {
+	return TransactionsWithOptions(transactions, DefaultOptions)
+}
+
+// TransactionsWithOptions is like Transactions, but uses the options to
+// match transfers.
+func TransactionsWithOptions(transactions []*transaction.Record, opts Options) ([]*Transaction, error) {
 	var normalized []*Transaction
 	for _, t := range transactions {
+		total, err := amount(t.AmountTCode)
+		if err != nil {
+			return nil, fmt.Errorf("%d: transaction: %w", t.Line, err)
+		}
+		// an unknown status is reported by UnknownClearedStatuses
+		cleared, _ := ParseClearedStatus(t.ClearedStatus)
 		xact := Transaction{
 			Line:          t.Line,
 			Type:          t.Type,
 			Date:          t.Date,
 			Account:       t.Account,
-			ClearedStatus: t.ClearedStatus,
+			ClearedStatus: cleared,
+			IsSplit:       len(t.Split) != 0,
 			IsZero:        true, // assume the worst
 			Memo:          t.Memo,
 			Payee:         t.Payee,
 			RefNo:         t.RefNo,
 			Ticker:        t.Ticker,
+			Total:         total,
 		}
 		if len(t.Split) == 0 {
 			xact.Memo = ""
-			split := Split{
-				Line:     t.Line,
-				Account:  t.ToAccount,
-				Amount:   t.AmountTCode,
-				Category: t.Category,
-				IsZero:   t.AmountTCode == "" || t.AmountTCode == "0.00",
-				Memo:     t.Memo,
-				Ticker:   t.Ticker,
-			}
-			xact.Split = append(xact.Split, &split)
+		}
+		if xact.Split, err = splits(t, total); err != nil {
+			return nil, err
+		}
+		for _, split := range xact.Split {
 			if !split.IsZero {
 				xact.IsZero = false
 			}
-		} else {
-			for i, line := range t.Split {
-				split := Split{
-					Line:     line.Line,
-					Account:  line.Account,
-					Amount:   line.Amount,
-					IsZero:   line.Amount == "" || line.Amount == "0.00",
-					Category: line.Category,
-					Memo:     line.Memo,
-				}
-				if i == 0 && split.Account == "" {
-					split.Account = t.ToAccount
-				}
-				xact.Split = append(xact.Split, &split)
-				if !split.IsZero {
-					xact.IsZero = false
-				}
-			}
 		}
 
-		// flag the receiving half of linked transactions
-		if len(xact.Split) == 1 || xact.Payee != "Opening Balance" {
-			switch xact.Type {
-			case "Oth L":
-				xact.IsLinked = xact.Split[0].Account != ""
-			}
+		normalized = append(normalized, &xact)
+	}
+	matchTransfers(normalized, opts.TransferWindow)
+	return normalized, nil
+}
+
+// splits returns the lines of a transaction. A transaction without any
+// split lines gets a single line for the T amount.
+func splits(t *transaction.Record, total stdlib.Amount) ([]*Split, error) {
+	if len(t.Split) == 0 {
+		return []*Split{{
+			Line:     t.Line,
+			Account:  t.ToAccount,
+			Amount:   total,
+			Category: t.Category,
+			IsZero:   total.IsZero(),
+			Memo:     t.Memo,
+			Parsed:   ParseCategory(t.Category),
+			Ticker:   t.Ticker,
+		}}, nil
+	}
+	var lines []*Split
+	for i, line := range t.Split {
+		amount, err := amount(line.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("%d: split: %w", line.Line, err)
 		}
+		split := Split{
+			Line:      line.Line,
+			Account:   line.Account,
+			Amount:    amount,
+			IsImplied: line.Implied,
+			IsZero:    amount.IsZero(),
+			Category:  line.Category,
+			Memo:      line.Memo,
+			Parsed:    ParseCategory(line.Category),
+		}
+		if i == 0 && split.Account == "" && t.ToAccount != "" {
+			split.Account, split.IsInherited = t.ToAccount, true
+		}
+		lines = append(lines, &split)
+	}
+	return lines, nil
+}
 
-		normalized = append(normalized, &xact)
+// amount parses a QIF amount. A missing amount is treated as zero.
+func amount(s string) (stdlib.Amount, error) {
+	if s == "" {
+		return stdlib.Amount{}, nil
 	}
-	return normalized
+	return stdlib.ParseAmount(s)
 }
@@ -0,0 +1,150 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/stdlib"
+)
+
+// leg identifies one side of a transfer. The other side has the accounts
+// swapped and the amount negated. The date isn't part of it since the
+// sides may be posted on different days.
+type leg struct {
+	from, to string
+	amount   stdlib.Amount
+}
+
+// matchTransfers pairs each transfer line with the line in the other
+// account that records the same transfer. Quicken writes both sides, so
+// one of them is flagged as linked to keep writers from counting the
+// transfer twice. The side in an investment account is always kept since
+// the investment records the cash leg. Otherwise, the side with more lines
+// is kept since it has more detail, or the side that comes first.
+//
+// The sides must be no more than window days apart. When more than one
+// line could be the other side, the one with the nearest date is used.
+//
+// Transfers to the transaction's own account (eg, opening balances)
+// aren't matched.
+func matchTransfers(transactions []*Transaction, window int) {
+	type pending struct {
+		t     *Transaction
+		split *Split
+	}
+	waiting := make(map[leg][]pending)
+	for _, t := range transactions {
+		for _, split := range t.Split {
+			if split.Account == "" || split.Account == t.Account {
+				continue
+			}
+			amount := legAmount(t, split)
+			other := leg{from: split.Account, to: t.Account, amount: amount.Neg()}
+			queue, nearest := waiting[other], -1
+			for i, p := range queue {
+				days := abs(t.Date.DaysSince(p.t.Date))
+				if days <= window && (nearest == -1 || days < abs(t.Date.DaysSince(queue[nearest].t.Date))) {
+					nearest = i
+				}
+			}
+			if nearest != -1 {
+				match := queue[nearest]
+				waiting[other] = append(queue[:nearest:nearest], queue[nearest+1:]...)
+				split.Transfer, match.split.Transfer = match.split, split
+				if match.t.Type == "Invst" {
+					split.IsLinked = true
+				} else if t.Type == "Invst" || len(t.Split) > len(match.t.Split) {
+					match.split.IsLinked = true
+				} else {
+					split.IsLinked = true
+				}
+				continue
+			}
+			key := leg{from: t.Account, to: split.Account, amount: amount}
+			waiting[key] = append(waiting[key], pending{t: t, split: split})
+		}
+	}
+
+	// a transaction is linked only if every line is recorded elsewhere
+	for _, t := range transactions {
+		t.IsLinked = len(t.Split) != 0
+		for _, split := range t.Split {
+			if !split.IsLinked {
+				t.IsLinked = false
+			}
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// legAmount returns the amount that the line moves into the account of
+// the transaction. Quicken writes the cash leg of an investment without
+// a sign, so the direction comes from the action.
+func legAmount(t *Transaction, split *Split) stdlib.Amount {
+	if t.Type != "Invst" {
+		return split.Amount
+	}
+	action, err := ParseAction(t.RefNo)
+	if err != nil || !action.IsTransfer() {
+		return split.Amount
+	} else if action.IsCashIn() {
+		return split.Amount.Abs()
+	}
+	return split.Amount.Abs().Neg()
+}
+
+// UnmatchedTransfer is a transfer line that has no matching line in the
+// other account.
+type UnmatchedTransfer struct {
+	Line      int // the line of the split
+	Account   string
+	ToAccount string
+	Date      stdlib.CivilDate
+	Amount    stdlib.Amount
+}
+
+func (u UnmatchedTransfer) String() string {
+	return fmt.Sprintf("%d: unmatched transfer of %s on %s from %q to %q", u.Line, u.Amount, u.Date, u.Account, u.ToAccount)
+}
+
+// UnmatchedTransfers returns the transfer lines that weren't matched. It
+// is normal for transfers to accounts that weren't exported.
+func UnmatchedTransfers(transactions []*Transaction) []UnmatchedTransfer {
+	var unmatched []UnmatchedTransfer
+	for _, t := range transactions {
+		for _, split := range t.Split {
+			if split.Account != "" && split.Account != t.Account && split.Transfer == nil {
+				unmatched = append(unmatched, UnmatchedTransfer{Line: split.Line, Account: t.Account, ToAccount: split.Account, Date: t.Date, Amount: split.Amount})
+			}
+		}
+	}
+	return unmatched
+}
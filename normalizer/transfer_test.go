@@ -0,0 +1,252 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package normalizer_test
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"testing"
+)
+
+func TestTransfers(t *testing.T) {
+	// Specification: Transfer matching
+
+	// Given a transfer between two bank accounts, a split paycheck that
+	// deposits part of it in savings, an opening balance and a transfer
+	// to an account that wasn't exported
+	input := `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+NSavings
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 1'16
+T100.00
+POpening Balance
+L[Checking]
+^
+D1/ 4'16
+T-50.00
+PTransfer
+L[Savings]
+^
+D1/15'16
+T1,000.00
+PPaycheck
+SSalary
+$900.00
+S[Savings]
+$100.00
+^
+D1/20'16
+T-25.00
+PPayment
+L[Visa]
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T50.00
+PTransfer
+L[Checking]
+^
+D1/15'16
+T-100.00
+PPaycheck
+L[Checking]
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are normalized
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 6 {
+		t.Fatalf("transactions: yields %d: expected 6\n", len(transactions))
+	}
+	opening, transfer, paycheck, payment, deposit, saving := transactions[0], transactions[1], transactions[2], transactions[3], transactions[4], transactions[5]
+
+	// Then the transfer between the bank accounts is linked on one side
+	if transfer.IsLinked || !deposit.IsLinked {
+		t.Errorf("transfer: yields %v, %v: expected false, true\n", transfer.IsLinked, deposit.IsLinked)
+	}
+	if transfer.Split[0].Transfer != deposit.Split[0] || deposit.Split[0].Transfer != transfer.Split[0] {
+		t.Errorf("transfer: expected the legs to refer to each other\n")
+	}
+
+	// And the split paycheck is kept while the single line in savings is linked
+	if paycheck.IsLinked || paycheck.Split[1].IsLinked || !saving.IsLinked {
+		t.Errorf("paycheck: yields %v, %v, %v: expected false, false, true\n", paycheck.IsLinked, paycheck.Split[1].IsLinked, saving.IsLinked)
+	}
+
+	// And the opening balance isn't a transfer
+	if opening.IsLinked || opening.Split[0].Transfer != nil {
+		t.Errorf("opening balance: expected it not to be linked\n")
+	}
+
+	// And the transfer to the missing account is reported
+	unmatched := normalizer.UnmatchedTransfers(transactions)
+	if len(unmatched) != 1 {
+		t.Fatalf("unmatched: yields %d: expected 1\n", len(unmatched))
+	}
+	if u := unmatched[0]; u.Line != payment.Line || u.ToAccount != "Visa" || u.Amount.String() != "-25.00" {
+		t.Errorf("unmatched: yields %s: expected the payment to Visa\n", u)
+	}
+	if payment.IsLinked {
+		t.Errorf("payment: yields linked: expected not linked\n")
+	}
+}
+
+func TestTransferDates(t *testing.T) {
+	// Specification: Transfer matching across dates
+
+	// Given a transfer that savings posts a day after checking, two
+	// transfers of the same amount two days apart, and a transfer that
+	// savings posts too late to match
+	input := `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+NSavings
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T-50.00
+PTransfer
+L[Savings]
+^
+D2/ 6'16
+T-20.00
+PTransfer
+L[Savings]
+^
+D2/ 8'16
+T-20.00
+PTransfer
+L[Savings]
+^
+D3/ 1'16
+T-75.00
+PTransfer
+L[Savings]
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D1/ 5'16
+T50.00
+PTransfer
+L[Checking]
+^
+D2/ 9'16
+T20.00
+PTransfer
+L[Checking]
+^
+D3/ 8'16
+T75.00
+PTransfer
+L[Checking]
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are normalized with the default window
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 7 {
+		t.Fatalf("transactions: yields %d: expected 7\n", len(transactions))
+	}
+	january, february, week, march := transactions[0], transactions[1], transactions[2], transactions[3]
+	deposit, second, late := transactions[4], transactions[5], transactions[6]
+
+	// Then the legs a day apart are matched
+	if january.Split[0].Transfer != deposit.Split[0] || !deposit.IsLinked {
+		t.Errorf("a day apart: expected the legs to refer to each other\n")
+	}
+
+	// And the deposit is matched with the nearest transfer
+	if week.Split[0].Transfer != second.Split[0] || february.Split[0].Transfer != nil {
+		t.Errorf("nearest: expected the deposit on 2/9 to match the transfer on 2/8, not 2/6\n")
+	}
+
+	// And the legs a week apart are not matched
+	if march.Split[0].Transfer != nil || late.Split[0].Transfer != nil {
+		t.Errorf("a week apart: expected the legs not to be matched\n")
+	}
+	if unmatched := normalizer.UnmatchedTransfers(transactions); len(unmatched) != 3 {
+		t.Errorf("unmatched: yields %d: expected 3\n", len(unmatched))
+	}
+
+	// When they are normalized with a window of a week
+	transactions, err = normalizer.TransactionsWithOptions(r.Transactions, normalizer.Options{TransferWindow: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then the legs a week apart are matched
+	if transactions[3].Split[0].Transfer != transactions[6].Split[0] {
+		t.Errorf("window of a week: expected the legs to refer to each other\n")
+	}
+}
@@ -27,24 +27,28 @@ package account
 import (
 	"fmt"
 	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
 )
 
 type Record struct {
-	Line                 int `json:"-"`
-	Col                  int `json:"-"`
+	Line                 int          `json:"-"`
+	Col                  int          `json:"-"`
+	Span                 scanner.Span `json:"-"` // source lines, including the terminator
 	CreditLimit          string
 	Description          string
 	Name                 string
 	StatementBalance     string
-	StatementBalanceDate string
+	StatementBalanceDate stdlib.CivilDate
 	Type                 string
+	Extra                map[string][]string `json:"extra,omitempty"` // unknown fields, by code
 }
 
 func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 	saved, sname, record := sc, "account", Record{Line: sc.Line, Col: sc.Col}
 
 	var found bool
-	var creditLimit, descr, name, statementBalance, statementBalanceDate, typ []byte
+	var creditLimit, descr, name, statementBalance, typ []byte
+	var statementBalanceDate *stdlib.CivilDate
 	for {
 		if creditLimit == nil {
 			if creditLimit, sc = sc.Field("L"); creditLimit != nil {
@@ -72,7 +76,7 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 		}
 		if statementBalanceDate == nil {
 			if statementBalanceDate, sc = sc.Date("/"); statementBalanceDate != nil {
-				found, record.StatementBalanceDate = true, string(statementBalanceDate)
+				found, record.StatementBalanceDate = true, *statementBalanceDate
 				continue
 			}
 		}
@@ -83,6 +87,17 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 			}
 		}
 
+		if sc.Passthrough {
+			if code, value, bb := sc.Unknown(); value != nil {
+				if record.Extra == nil {
+					record.Extra = make(map[string][]string)
+				}
+				found, record.Extra[code] = true, append(record.Extra[code], string(value))
+				sc = bb
+				continue
+			}
+		}
+
 		break
 	}
 
@@ -92,14 +107,21 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 
 	// check for required fields
 	if name == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing field %q", record.Line, sname, "name")
+		return nil, sc, &scanner.Error{Line: record.Line, Col: record.Col, Record: sname, Reason: fmt.Sprintf("missing field %q", "name")}
 	}
 
 	eor, bb := sc.EndOfRecord()
 	if eor == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing record terminator", sc.Line, sname)
+		err := &scanner.Error{Line: sc.Line, Col: sc.Col, Record: sname, Reason: "missing record terminator"}
+		if rpt, _ := sc.Repeat(saved); rpt != nil {
+			// the field starts the next record, so keep this one
+			record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
+			return &record, sc, err
+		}
+		return nil, sc, err
 	}
 	sc = bb
+	record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
 
 	return &record, sc, nil
 }
@@ -0,0 +1,131 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reader_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// benchmarkTransactions is the number of transactions in the generated file.
+const benchmarkTransactions = 1000000
+
+var benchmark struct {
+	once  sync.Once
+	input []byte
+}
+
+// benchmarkSection is the number of transactions in each register.
+const benchmarkSection = 10000
+
+// benchmarkInput returns a QIF file with a category list, two accounts and
+// benchmarkTransactions transactions. The registers switch between the
+// accounts every benchmarkSection transactions. Every fourth transaction
+// is split.
+func benchmarkInput() []byte {
+	benchmark.once.Do(func() {
+		var buf bytes.Buffer
+		buf.WriteString("!Type:Cat\nNGroceries\nE\n^\nNDining\nE\n^\nNSalary\nI\n^\n")
+		buf.WriteString("!Option:AutoSwitch\n!Account\nNChecking\nTBank\n^\nNVisa\nTCCard\n^\n!Clear:AutoSwitch\n")
+		for i := 0; i < benchmarkTransactions; i++ {
+			if i%(2*benchmarkSection) == 0 {
+				buf.WriteString("!Account\nNChecking\nTBank\n^\n!Type:Bank\n")
+			} else if i%benchmarkSection == 0 {
+				buf.WriteString("!Account\nNVisa\nTCCard\n^\n!Type:CCard\n")
+			}
+			fmt.Fprintf(&buf, "D%d/%2d'%02d\n", i%12+1, i%28+1, i%20)
+			fmt.Fprintf(&buf, "N%d\nCX\nPPayee Number %d\nMMemo for transaction %d\n", i, i%1000, i)
+			if i%4 == 0 {
+				buf.WriteString("T-75.00\nSGroceries\nEFood\n$-50.00\nSDining\n$-25.00\n^\n")
+			} else {
+				fmt.Fprintf(&buf, "T-%d.%02d\nLGroceries\n^\n", i%500, i%100)
+			}
+		}
+		benchmark.input = buf.Bytes()
+	})
+	return benchmark.input
+}
+
+func BenchmarkNew(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scanner.New(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	benchmarkRead(b, reader.Options{})
+}
+
+func BenchmarkReadParallel(b *testing.B) {
+	benchmarkRead(b, reader.Options{Workers: runtime.NumCPU()})
+}
+
+func benchmarkRead(b *testing.B, opts reader.Options) {
+	input := benchmarkInput()
+	sc, err := scanner.New(input)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, _, err := reader.ReadWithOptions(sc, opts)
+		if err != nil {
+			b.Fatal(err)
+		} else if len(r.Transactions) != benchmarkTransactions {
+			b.Fatalf("read yields %d transactions: expected %d\n", len(r.Transactions), benchmarkTransactions)
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := reader.NewDecoder(bytes.NewReader(input))
+		for {
+			if _, err := d.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
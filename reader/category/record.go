@@ -32,12 +32,14 @@ import (
 type Record struct {
 	Line         int
 	Col          int
+	Span         scanner.Span // source lines, including the terminator
 	BudgetAmount []string
 	Description  string
 	IsIncome     bool // quicken assumes default is expense category
 	IsTaxRelated bool
 	Name         string
 	TaxSchedule  string
+	Extra        map[string][]string // unknown fields, by code
 }
 
 func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
@@ -77,7 +79,7 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 		}
 		if taxRelated == nil {
 			if taxRelated, sc = sc.Field("T"); taxRelated != nil {
-				found, record.IsTaxRelated = true, false
+				found, record.IsTaxRelated = true, true
 				continue
 			}
 		}
@@ -89,6 +91,17 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 			}
 		}
 
+		if sc.Passthrough {
+			if code, value, bb := sc.Unknown(); value != nil {
+				if record.Extra == nil {
+					record.Extra = make(map[string][]string)
+				}
+				found, record.Extra[code] = true, append(record.Extra[code], string(value))
+				sc = bb
+				continue
+			}
+		}
+
 		break
 	}
 
@@ -98,14 +111,21 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 
 	// check for required fields
 	if name == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing field %q", record.Line, sname, "name")
+		return nil, sc, &scanner.Error{Line: record.Line, Col: record.Col, Record: sname, Reason: fmt.Sprintf("missing field %q", "name")}
 	}
 
 	eor, bb := sc.EndOfRecord()
 	if eor == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing record terminator", sc.Line, sname)
+		err := &scanner.Error{Line: sc.Line, Col: sc.Col, Record: sname, Reason: "missing record terminator"}
+		if rpt, _ := sc.Repeat(saved); rpt != nil {
+			// the field starts the next record, so keep this one
+			record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
+			return &record, sc, err
+		}
+		return nil, sc, err
 	}
 	sc = bb
+	record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
 
 	return &record, sc, nil
 }
@@ -37,6 +37,16 @@ type Section struct {
 	Records []*Record
 }
 
+// Read reads a record and adds it to the section. It returns false if
+// it doesn't find a record.
+func (s *Section) Read(sc scanner.Scanner) (bool, scanner.Scanner, error) {
+	record, sc, err := ReadRecord(sc)
+	if record != nil {
+		s.Records = append(s.Records, record)
+	}
+	return record != nil, sc, err
+}
+
 func ReadSection(sc scanner.Scanner) (*Section, scanner.Scanner, error) {
 	saved, sname, section := sc, "categories", Section{Line: sc.Line, Col: sc.Col}
 
@@ -47,16 +57,14 @@ func ReadSection(sc scanner.Scanner) (*Section, scanner.Scanner, error) {
 	sc = bb
 
 	// read the section detail
-	var err error
 	for {
-		var record *Record
-		record, sc, err = ReadRecord(sc)
-		if err != nil {
+		var found bool
+		var err error
+		if found, sc, err = section.Read(sc); err != nil {
 			return nil, sc, fmt.Errorf("%d: %s: %w", section.Line, sname, err)
-		} else if record == nil {
+		} else if !found {
 			break
 		}
-		section.Records = append(section.Records, record)
 	}
 
 	// read the end of section marker
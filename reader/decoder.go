@@ -0,0 +1,397 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/maloquacious/qif/reader/account"
+	"github.com/maloquacious/qif/reader/category"
+	"github.com/maloquacious/qif/reader/class"
+	"github.com/maloquacious/qif/reader/invoice"
+	"github.com/maloquacious/qif/reader/security"
+	"github.com/maloquacious/qif/reader/tag"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
+	"io"
+	"unicode/utf8"
+)
+
+// Item is one of *account.Record, *category.Record, *class.Record,
+// *invoice.Record, *security.Record, *tag.Record or *transaction.Record.
+// Memorized transactions and prices are returned as transaction records
+// with a Type of "Memorized" or "Prices". With Passthrough set, unknown
+// sections are returned as *RawSection.
+type Item interface{}
+
+// Decoder reads QIF data one record at a time. Unlike Read, it only holds
+// the lines of the current record in memory, so it can be used on files
+// that are too large to load all at once.
+//
+// Accounts are returned from the account list only. The single account
+// blocks that come before each transaction section update the active
+// account, which is copied into every transaction record.
+//
+// The decoder can't look ahead to detect the date format, so with
+// stdlib.DateAuto each date is parsed with the first format that fits.
+// Set Dates if the format is known.
+//
+// The decoder also can't detect the encoding from the whole file. It
+// checks for a byte order mark and for UTF-16 at the start of the input.
+// After that, with stdlib.EncodingAuto, lines that aren't valid UTF-8 are
+// translated from Windows-1252. Set Encoding if the encoding is known.
+//
+// Set Passthrough to keep unknown fields and sections. The decoder returns
+// a RawSection for each record in an unknown section rather than holding
+// the whole section in memory. Its Line is the first line of the record.
+type Decoder struct {
+	Dates       stdlib.DateOptions
+	Encoding    stdlib.Encoding
+	Passthrough bool
+
+	r        *bufio.Reader
+	encoding stdlib.Encoding // encoding of the lines from r
+	started  bool            // true once the encoding has been checked
+	line     int             // line number of the next line of input
+	offset   int             // byte offset of the next line, as Read counts it
+	pending  []byte          // line that was read too far
+	chunk    []byte          // lines of the current record, reused between records
+	eof      bool
+
+	active struct {
+		account     string
+		accountType string
+	}
+	accounts struct {
+		listed  bool // true once the account list has been read
+		pending []*account.Record
+	}
+	section struct {
+		line        int
+		name        string
+		header      string // header of an unknown section
+		accountType string
+		list        bool // true if the section is the account list
+	}
+}
+
+// NewDecoder returns a decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), line: 1}
+}
+
+// Next returns the next record from the input.
+// It returns io.EOF when there are no more records.
+func (d *Decoder) Next() (Item, error) {
+	for {
+		start, offset, chunk := d.line, d.offset, d.chunk[:0]
+		unterminated := false // the record ended at a section header
+		for {
+			line, err := d.readLine()
+			if err != nil {
+				return nil, err
+			} else if line == nil {
+				break
+			} else if line[0] == '!' && len(chunk) != 0 && d.section.name == "unknown" {
+				// raw sections don't need a terminator
+				d.unread(line)
+				break
+			} else if line[0] == '!' && len(chunk) != 0 {
+				// keep the header for the next call so that the decoder
+				// moves on to its section after reporting the record
+				d.unread(line)
+				unterminated = true
+				break
+			} else if line[0] == '!' {
+				if err := d.header(line, start); err != nil {
+					return nil, err
+				}
+				start, offset = d.line, d.offset
+				continue
+			}
+			chunk = appendLine(chunk, line)
+			if line[0] == '^' {
+				break
+			}
+		}
+
+		d.chunk = chunk
+		if len(chunk) == 0 {
+			if err := d.endSection(); err != nil {
+				return nil, err
+			}
+			d.eof = true
+			return nil, io.EOF
+		}
+
+		item, err := d.record(chunk, start, offset)
+		if err != nil {
+			return nil, err
+		} else if unterminated {
+			// the header line is next, so d.line is its line number
+			return nil, &ParseError{Line: d.line, Col: 1, Section: d.section.name, Reason: "missing record terminator"}
+		} else if item != nil {
+			return item, nil
+		}
+	}
+}
+
+// appendLine appends a line to the chunk without its carriage returns,
+// the same as scanner.New does.
+func appendLine(chunk, line []byte) []byte {
+	for {
+		cr := bytes.IndexByte(line, '\r')
+		if cr == -1 {
+			return append(chunk, line...)
+		}
+		chunk, line = append(chunk, line[:cr]...), line[cr+1:]
+	}
+}
+
+// readLine returns the next line of input, including the new-line.
+// It returns nil at the end of input. The line is only valid until the
+// next call.
+func (d *Decoder) readLine() ([]byte, error) {
+	if d.pending != nil {
+		line := d.pending
+		d.pending, d.line, d.offset = nil, d.line+1, d.offset+width(line)
+		return line, nil
+	} else if d.eof {
+		return nil, nil
+	} else if !d.started {
+		d.start()
+	}
+	line, err := d.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// the line is longer than the reader's buffer
+		long := append([]byte{}, line...)
+		for err == bufio.ErrBufferFull {
+			line, err = d.r.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if err == io.EOF {
+		if len(line) == 0 {
+			return nil, nil
+		}
+		// copy so that the reader's buffer isn't changed
+		line = append(line[:len(line):len(line)], '\n')
+	} else if err != nil {
+		return nil, err
+	}
+	switch d.encoding {
+	case stdlib.EncodingWindows1252, stdlib.EncodingLatin1:
+		// single byte encodings can't fail
+		line, _, _ = stdlib.Transcode(line, d.encoding)
+	case stdlib.EncodingAuto:
+		if !utf8.Valid(line) {
+			line, _, _ = stdlib.Transcode(line, stdlib.EncodingWindows1252)
+		}
+	default:
+		if !utf8.Valid(line) {
+			return nil, fmt.Errorf("utf8: import: invalid utf-8 character on line %d", d.line)
+		}
+	}
+	d.line, d.offset = d.line+1, d.offset+width(line)
+	return line, nil
+}
+
+// width returns the number of bytes that the line takes up in a scanner.
+// The scanner drops carriage returns.
+func width(line []byte) int {
+	return len(line) - bytes.Count(line, []byte{'\r'})
+}
+
+// start removes the byte order mark and switches to a UTF-16 reader
+// if the input needs one. Lines from a UTF-16 reader are UTF-8.
+func (d *Decoder) start() {
+	d.started, d.encoding = true, d.Encoding
+	prefix, _ := d.r.Peek(1024)
+	if d.encoding == stdlib.EncodingAuto {
+		switch detected := stdlib.DetectEncoding(prefix); detected {
+		case stdlib.EncodingUTF16LE, stdlib.EncodingUTF16BE:
+			d.encoding = detected
+		default:
+			if bytes.HasPrefix(prefix, []byte{0xEF, 0xBB, 0xBF}) {
+				d.encoding = stdlib.EncodingUTF8
+			}
+		}
+	}
+	switch d.encoding {
+	case stdlib.EncodingUTF8:
+		if bytes.HasPrefix(prefix, []byte{0xEF, 0xBB, 0xBF}) {
+			_, _ = d.r.Discard(3)
+		}
+	case stdlib.EncodingUTF16LE, stdlib.EncodingUTF16BE:
+		d.r = bufio.NewReader(stdlib.NewUTF16Reader(d.r, d.encoding == stdlib.EncodingUTF16BE))
+		d.encoding = stdlib.EncodingUTF8
+	}
+}
+
+// unread pushes a line back so that it is returned by the next readLine.
+func (d *Decoder) unread(line []byte) {
+	d.pending, d.line, d.offset = append([]byte{}, line...), d.line-1, d.offset-width(line)
+}
+
+// header starts a new section.
+func (d *Decoder) header(line []byte, lineNo int) error {
+	if err := d.endSection(); err != nil {
+		return err
+	}
+
+	d.section.line, d.section.name, d.section.header, d.section.accountType, d.section.list = lineNo, "", "", "", false
+	switch header := string(bytes.TrimRight(line, " \t\r\n")); header {
+	case "!Clear:AutoSwitch", "!Option:AutoSwitch":
+		// ignore
+	case "!Account":
+		d.section.name = "accounts"
+	case "!Type:Cat":
+		d.section.name = "categories"
+	case "!Type:Security":
+		d.section.name = "securities"
+	case "!Type:Tag":
+		d.section.name = "tags"
+	case "!Type:Class":
+		d.section.name = "classes"
+	case "!Type:Bill":
+		d.section.name, d.section.accountType = "bills", "Bill"
+	case "!Type:Invoice":
+		d.section.name, d.section.accountType = "invoices", "Invoice"
+	case "!Type:Memorized":
+		d.section.name, d.section.accountType = "transactions", "Memorized"
+	case "!Type:Prices":
+		d.section.name, d.section.accountType = "transactions", "Prices"
+	default:
+		if d.active.accountType != "" && header == "!Type:"+d.active.accountType {
+			switch d.active.accountType {
+			case "Bank", "Cash", "CCard", "Invst", "Oth A", "Oth L":
+			default:
+				return &ParseError{Line: lineNo, Col: 1, Section: "transactions", Reason: fmt.Sprintf("unsupported account type %q", d.active.accountType), Err: ErrUnsupportedAccountType}
+			}
+			d.section.name, d.section.accountType = "transactions", d.active.accountType
+		} else if d.Passthrough {
+			d.section.name, d.section.header = "unknown", string(bytes.TrimRight(line, "\r\n"))
+		} else {
+			return &ParseError{Line: lineNo, Col: 1, Reason: fmt.Sprintf("unknown section %q", bytes.TrimRight(line, "\r\n"))}
+		}
+	}
+	return nil
+}
+
+// endSection updates the active account when an account block ends.
+func (d *Decoder) endSection() error {
+	if d.section.name != "accounts" || d.section.list {
+		return nil
+	}
+	pending := d.accounts.pending
+	d.accounts.pending = nil
+	switch len(pending) {
+	case 0:
+		return nil
+	case 1:
+		d.active.account, d.active.accountType = pending[0].Name, pending[0].Type
+		return nil
+	}
+	return &ParseError{Line: d.section.line, Col: 1, Section: d.section.name, Reason: fmt.Sprintf("found %d records in account header", len(pending))}
+}
+
+// record parses the lines of a single record.
+// It returns nil if the record only updates the decoder's state.
+func (d *Decoder) record(chunk []byte, lineNo, offset int) (Item, error) {
+	// the lines have already been translated to UTF-8 and the records
+	// copy the text that they keep, so the chunk can be scanned in place.
+	sc := scanner.Scanner{Buffer: chunk, Line: lineNo, Col: 1, Offset: offset, Dates: d.Dates, Passthrough: d.Passthrough}
+	var err error
+
+	var item Item
+	switch d.section.name {
+	case "accounts":
+		var record *account.Record
+		if record, sc, err = account.ReadRecord(sc); record != nil {
+			if !d.accounts.listed || d.section.list {
+				d.accounts.listed, d.section.list = true, true
+				item = record
+			} else {
+				d.accounts.pending = append(d.accounts.pending, record)
+			}
+		}
+	case "categories":
+		var record *category.Record
+		if record, sc, err = category.ReadRecord(sc); record != nil {
+			item = record
+		}
+	case "securities":
+		var record *security.Record
+		if record, sc, err = security.ReadRecord(sc); record != nil {
+			item = record
+		}
+	case "tags":
+		var record *tag.Record
+		if record, sc, err = tag.ReadRecord(sc); record != nil {
+			item = record
+		}
+	case "classes":
+		var record *class.Record
+		if record, sc, err = class.ReadRecord(sc); record != nil {
+			item = record
+		}
+	case "bills", "invoices":
+		var record *invoice.Record
+		if record, sc, err = invoice.ReadRecord(sc, d.section.accountType); record != nil {
+			item = record
+		}
+	case "transactions":
+		var record *transaction.Record
+		account := d.active.account
+		if d.section.accountType == "Memorized" || d.section.accountType == "Prices" {
+			account = ""
+		}
+		if record, sc, err = transaction.ReadRecord(sc, account, d.section.accountType); record != nil {
+			item = record
+		}
+	case "unknown":
+		raw := &RawSection{Line: lineNo, Header: d.section.header}
+		for _, line := range bytes.Split(bytes.TrimRight(chunk, "\n"), []byte{'\n'}) {
+			raw.Lines = append(raw.Lines, string(bytes.TrimRight(line, "\r")))
+		}
+		return raw, nil
+	default:
+		return nil, &ParseError{Line: sc.Line, Col: sc.Col, Reason: "unexpected input"}
+	}
+	if err != nil && item != nil {
+		diag := unterminated(d.section.name, sc)
+		return nil, &ParseError{Line: diag.Line, Col: diag.Col, Section: diag.Section, Reason: diag.Reason, Err: err}
+	} else if err != nil {
+		diag := malformed(d.section.name, sc, err)
+		return nil, &ParseError{Line: diag.Line, Col: diag.Col, Section: diag.Section, Reason: diag.Reason, Err: err}
+	} else if item == nil && (d.section.name != "accounts" || len(sc.Buffer) != 0) {
+		diag := unexpected(d.section.name, sc)
+		return nil, &ParseError{Line: diag.Line, Col: diag.Col, Section: diag.Section, Reason: diag.Reason}
+	}
+	return item, nil
+}
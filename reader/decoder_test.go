@@ -0,0 +1,250 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reader_test
+
+import (
+	"errors"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/account"
+	"github.com/maloquacious/qif/reader/category"
+	"github.com/maloquacious/qif/reader/security"
+	"github.com/maloquacious/qif/reader/tag"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/scanner"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const input = `!Type:Tag
+NVacation
+^
+!Type:Cat
+NSalary
+I
+^
+NUtilities
+E
+^
+!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+NVisa
+TCCard
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T-54.25
+PCity Power
+LUtilities
+^
+D1/15'16
+T2,500.00
+PAcme Corp
+SSalary
+$3,000.00
+S[Visa]
+$-500.00
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D1/20'16
+T-500.00
+L[Checking]
+^
+!Type:Security
+NApple Inc.
+SAAPL
+^
+!Type:Memorized
+KP
+T-54.25
+PCity Power
+^
+!Type:Prices
+"AAPL",107.73,"9/ 3'16"
+^
+`
+
+func TestDecoder(t *testing.T) {
+	// Specification: Decoder
+
+	// Given a QIF file
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the file is decoded one record at a time
+	var yields reader.Reader
+	d := reader.NewDecoder(strings.NewReader(input))
+	for {
+		item, err := d.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		switch item := item.(type) {
+		case *account.Record:
+			if yields.Accounts == nil {
+				yields.Accounts = &account.Section{}
+			}
+			yields.Accounts.Records = append(yields.Accounts.Records, item)
+		case *category.Record:
+			if yields.Categories == nil {
+				yields.Categories = &category.Section{}
+			}
+			yields.Categories.Records = append(yields.Categories.Records, item)
+		case *security.Record:
+			if yields.Securities == nil {
+				yields.Securities = &security.Section{}
+			}
+			yields.Securities.Records = append(yields.Securities.Records, item)
+		case *tag.Record:
+			if yields.Tags == nil {
+				yields.Tags = &tag.Section{}
+			}
+			yields.Tags.Records = append(yields.Tags.Records, item)
+		case *transaction.Record:
+			switch item.Type {
+			case "Memorized":
+				yields.Memorized = append(yields.Memorized, item)
+			case "Prices":
+				yields.Prices = append(yields.Prices, item)
+			default:
+				yields.Transactions = append(yields.Transactions, item)
+			}
+		default:
+			t.Fatalf("unexpected item %T", item)
+		}
+	}
+
+	// Then it yields the same records as Read
+	if !reflect.DeepEqual(expected.Accounts.Records, yields.Accounts.Records) {
+		t.Errorf("accounts: expected %+v: yields %+v\n", expected.Accounts.Records, yields.Accounts.Records)
+	}
+	if !reflect.DeepEqual(expected.Categories.Records, yields.Categories.Records) {
+		t.Errorf("categories: expected %+v: yields %+v\n", expected.Categories.Records, yields.Categories.Records)
+	}
+	if !reflect.DeepEqual(expected.Securities.Records, yields.Securities.Records) {
+		t.Errorf("securities: expected %+v: yields %+v\n", expected.Securities.Records, yields.Securities.Records)
+	}
+	if !reflect.DeepEqual(expected.Tags.Records, yields.Tags.Records) {
+		t.Errorf("tags: expected %+v: yields %+v\n", expected.Tags.Records, yields.Tags.Records)
+	}
+	if !reflect.DeepEqual(expected.Transactions, yields.Transactions) {
+		t.Errorf("transactions: expected %+v: yields %+v\n", expected.Transactions, yields.Transactions)
+	}
+	if !reflect.DeepEqual(expected.Memorized, yields.Memorized) {
+		t.Errorf("memorized: expected %+v: yields %+v\n", expected.Memorized, yields.Memorized)
+	}
+	if !reflect.DeepEqual(expected.Prices, yields.Prices) {
+		t.Errorf("prices: expected %+v: yields %+v\n", expected.Prices, yields.Prices)
+	}
+
+	// When the record terminator is missing before the next section
+	d = reader.NewDecoder(strings.NewReader("!Type:Cat\nNSalary\n!Type:Tag\nNVacation\n^\n"))
+	_, err = d.Next()
+
+	// Then it returns an error at the header
+	var perr *reader.ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("missing terminator: expected *ParseError: yields %v\n", err)
+	} else if perr.Line != 3 || perr.Section != "categories" || perr.Reason != "missing record terminator" {
+		t.Errorf("missing terminator: yields %d: %s: %q: expected 3: categories: %q\n", perr.Line, perr.Section, perr.Reason, "missing record terminator")
+	}
+
+	// And the next call reads the following section
+	item, err := d.Next()
+	if err != nil {
+		t.Fatalf("next section: expected nil: yields %v\n", err)
+	}
+	if record, ok := item.(*tag.Record); !ok || record.Name != "Vacation" || record.Line != 4 {
+		t.Errorf("next section: expected tag %q on line 4: yields %+v\n", "Vacation", item)
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("next section: expected io.EOF: yields %v\n", err)
+	}
+}
+
+func TestDecoderEncoding(t *testing.T) {
+	// Specification: Decoder encodings
+
+	// Given a QIF file in UTF-16 with a byte order mark
+	utf16le := []byte{0xFF, 0xFE}
+	for _, r := range input {
+		utf16le = append(utf16le, byte(r), 0)
+	}
+
+	// When it is decoded
+	// Then it yields the same number of records as the UTF-8 file
+	count := func(r io.Reader) int {
+		var items int
+		d := reader.NewDecoder(r)
+		for {
+			if _, err := d.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			items++
+		}
+		return items
+	}
+	if expected, yields := count(strings.NewReader(input)), count(strings.NewReader(string(utf16le))); expected != yields {
+		t.Errorf("utf-16le yields %d records: expected %d\n", yields, expected)
+	}
+
+	// Given a record with a Windows-1252 payee
+	cp1252 := "!Type:Memorized\nKP\nT-4.50\nPCaf\xe9 \x93Bistro\x94\n^\n"
+
+	// When it is decoded
+	d := reader.NewDecoder(strings.NewReader(cp1252))
+	item, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then the payee is translated to UTF-8
+	if record, ok := item.(*transaction.Record); !ok || record.Payee != "Café “Bistro”" {
+		t.Errorf("windows-1252 yields %+v: expected %q\n", item, "Café “Bistro”")
+	}
+}
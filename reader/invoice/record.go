@@ -0,0 +1,235 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+package invoice
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
+)
+
+// Record is a bill or an invoice. The X fields are the extensions that
+// business exports use for due dates, taxes and line items.
+type Record struct {
+	Line       int                 `json:"-"`
+	Col        int                 `json:"-"`
+	Span       scanner.Span        `json:"-"`    // source lines, including the terminator
+	Type       string              `json:"type"` // Bill or Invoice
+	Address    []string            `json:"address,omitempty"`
+	Category   string              `json:"category,omitempty"`
+	Date       stdlib.CivilDate    `json:"date"`
+	DueDate    stdlib.CivilDate    `json:"due_date"`
+	Items      []*Item             `json:"items,omitempty"`
+	Kind       string              `json:"kind,omitempty"` // XI, the type of invoice transaction
+	Memo       string              `json:"memo,omitempty"`
+	Number     string              `json:"number,omitempty"`
+	Payee      string              `json:"payee,omitempty"`
+	TaxAccount string              `json:"tax_account,omitempty"`
+	TaxAmount  string              `json:"tax_amount,omitempty"`
+	TaxRate    string              `json:"tax_rate,omitempty"`
+	Total      string              `json:"total,omitempty"`
+	Extra      map[string][]string `json:"extra,omitempty"` // unknown fields, by code
+}
+
+// Item is a line item. Each item starts with an XS (description) line.
+type Item struct {
+	Line        int          `json:"-"`
+	Col         int          `json:"-"`
+	Span        scanner.Span `json:"-"`
+	Category    string       `json:"category,omitempty"`
+	Description string       `json:"descr,omitempty"`
+	Price       string       `json:"price,omitempty"`
+	Quantity    string       `json:"quantity,omitempty"`
+	Taxable     bool         `json:"taxable,omitempty"`
+}
+
+func ReadRecord(sc scanner.Scanner, typ string) (*Record, scanner.Scanner, error) {
+	saved, sname, record := sc, "invoice", Record{Line: sc.Line, Col: sc.Col, Type: typ}
+	if typ == "Bill" {
+		sname = "bill"
+	}
+
+	var found bool
+	var date, dueDate *stdlib.CivilDate
+	var category, kind, memo, number, payee, taxAccount, taxAmount, taxRate, total []byte
+	var item *Item
+	for {
+		if addrLine, bb := sc.Field("A"); addrLine != nil {
+			found, record.Address = true, append(record.Address, string(addrLine))
+			sc = bb
+			continue
+		}
+		if category == nil {
+			if category, sc = sc.Field("L"); category != nil {
+				found, record.Category = true, string(category)
+				continue
+			}
+		}
+		if date == nil {
+			if date, sc = sc.Date("D"); date != nil {
+				found, record.Date = true, *date
+				continue
+			}
+		}
+		if memo == nil {
+			if memo, sc = sc.Field("M"); memo != nil {
+				found, record.Memo = true, string(memo)
+				continue
+			}
+		}
+		if number == nil {
+			if number, sc = sc.Field("N"); number != nil {
+				found, record.Number = true, string(number)
+				continue
+			}
+		}
+		if payee == nil {
+			if payee, sc = sc.Field("P"); payee != nil {
+				found, record.Payee = true, string(payee)
+				continue
+			}
+		}
+		if total == nil {
+			if total, sc = sc.Field("T"); total != nil {
+				found, record.Total = true, string(total)
+				continue
+			}
+		}
+		if taxAccount == nil {
+			if taxAccount, sc = sc.Field("XC"); taxAccount != nil {
+				found, record.TaxAccount = true, string(taxAccount)
+				continue
+			}
+		}
+		if dueDate == nil {
+			if dueDate, sc = sc.Date("XE"); dueDate != nil {
+				found, record.DueDate = true, *dueDate
+				continue
+			}
+		}
+		if kind == nil {
+			if kind, sc = sc.Field("XI"); kind != nil {
+				found, record.Kind = true, string(kind)
+				continue
+			}
+		}
+		if taxRate == nil {
+			if taxRate, sc = sc.Field("XR"); taxRate != nil {
+				found, record.TaxRate = true, string(taxRate)
+				continue
+			}
+		}
+		if taxAmount == nil {
+			if taxAmount, sc = sc.Field("XT"); taxAmount != nil {
+				found, record.TaxAmount = true, string(taxAmount)
+				continue
+			}
+		}
+		if descr, bb := sc.Field("XS"); descr != nil {
+			item = &Item{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}}
+			found, record.Items = true, append(record.Items, item)
+			item.Description = string(descr)
+			item.Span.End = bb.Pos()
+			sc = bb
+			continue
+		}
+		if itemCategory, bb := sc.Field("XN"); itemCategory != nil {
+			if item == nil {
+				item = &Item{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}}
+				record.Items = append(record.Items, item)
+			}
+			found, item.Category = true, string(itemCategory)
+			item.Span.End = bb.Pos()
+			sc = bb
+			continue
+		}
+		if quantity, bb := sc.Field("X#"); quantity != nil {
+			if item == nil {
+				item = &Item{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}}
+				record.Items = append(record.Items, item)
+			}
+			found, item.Quantity = true, string(quantity)
+			item.Span.End = bb.Pos()
+			sc = bb
+			continue
+		}
+		if price, bb := sc.Field("X$"); price != nil {
+			if item == nil {
+				item = &Item{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}}
+				record.Items = append(record.Items, item)
+			}
+			found, item.Price = true, string(price)
+			item.Span.End = bb.Pos()
+			sc = bb
+			continue
+		}
+		if taxable, bb := sc.Field("XF"); taxable != nil {
+			if item == nil {
+				item = &Item{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}}
+				record.Items = append(record.Items, item)
+			}
+			found, item.Taxable = true, string(taxable) != "F" && string(taxable) != "0"
+			item.Span.End = bb.Pos()
+			sc = bb
+			continue
+		}
+
+		if sc.Passthrough {
+			if code, value, bb := sc.Unknown(); value != nil {
+				if record.Extra == nil {
+					record.Extra = make(map[string][]string)
+				}
+				found, record.Extra[code] = true, append(record.Extra[code], string(value))
+				sc = bb
+				continue
+			}
+		}
+
+		break
+	}
+
+	if !found { // no fields found
+		return nil, saved, nil
+	}
+
+	// check for required fields
+	if date == nil {
+		return nil, sc, &scanner.Error{Line: record.Line, Col: record.Col, Record: sname, Reason: fmt.Sprintf("missing field %q", "date")}
+	}
+
+	eor, bb := sc.EndOfRecord()
+	if eor == nil {
+		err := &scanner.Error{Line: sc.Line, Col: sc.Col, Record: sname, Reason: "missing record terminator"}
+		if rpt, _ := sc.Repeat(saved); rpt != nil {
+			// the field starts the next record, so keep this one
+			record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
+			return &record, sc, err
+		}
+		return nil, sc, err
+	}
+	sc = bb
+	record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
+
+	return &record, sc, nil
+}
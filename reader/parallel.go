@@ -0,0 +1,97 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reader
+
+import (
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/scanner"
+	"sync"
+)
+
+// job is a transaction section that is parsed on a worker.
+// Each job has its own parser so that the workers don't share state.
+type job struct {
+	sc          scanner.Scanner // the section, starting after the header
+	accountName string
+	accountType string
+	diagnostics int // number of diagnostics reported before the section
+
+	p       parser
+	records []*transaction.Record
+	err     error
+}
+
+// run parses the jobs on a pool of workers, then merges the results in
+// file order. It returns the first error in file order, which is the
+// error that the sequential path would have returned.
+func (p *parser) run(r *Reader) error {
+	queue := make(chan *job)
+	var wg sync.WaitGroup
+	for n := 0; n < p.workers && n < len(p.jobs); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				j.parse(p.lenient)
+			}
+		}()
+	}
+	for _, j := range p.jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+
+	// the diagnostics from the other sections are interleaved with the
+	// diagnostics from the jobs so that they stay in file order
+	var diagnostics []Diagnostic
+	prev := 0
+	for _, j := range p.jobs {
+		if j.err != nil {
+			return j.err
+		}
+		diagnostics = append(diagnostics, p.diagnostics[prev:j.diagnostics]...)
+		diagnostics = append(diagnostics, j.p.diagnostics...)
+		prev = j.diagnostics
+		switch j.accountType {
+		case "Memorized":
+			r.Memorized = append(r.Memorized, j.records...)
+		case "Prices":
+			r.Prices = append(r.Prices, j.records...)
+		default:
+			r.Transactions = append(r.Transactions, j.records...)
+		}
+	}
+	p.diagnostics, p.jobs = append(diagnostics, p.diagnostics[prev:]...), nil
+	return nil
+}
+
+// parse reads the records in the section.
+func (j *job) parse(lenient bool) {
+	j.p = parser{lenient: lenient}
+	section := &transaction.Section{Line: j.sc.Line, Col: j.sc.Col, Account: j.accountName, AccountType: j.accountType}
+	_, j.err = j.p.records(j.sc, "transactions", section.Read)
+	j.records = section.Records
+}
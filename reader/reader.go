@@ -26,19 +26,29 @@
 // converts it to structs with no attempt at cleaning up the data. If there
 // are errors that prevent parsing (mostly missing fields), it will return
 // only the first error found. The error should include the line and column
-// in the original data file to help with troubleshooting.
+// in the original data file to help with troubleshooting. In lenient mode,
+// it skips malformed records and returns a list of diagnostics instead.
 //
-// There are some errors that just cause a panic. I'm not sure why.
+// Errors are returned as a *ParseError with the position of the problem.
+//
+// Read needs the entire file in memory. Use a Decoder to process large
+// files one record at a time.
 package reader
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/maloquacious/qif/reader/account"
 	"github.com/maloquacious/qif/reader/category"
+	"github.com/maloquacious/qif/reader/class"
+	"github.com/maloquacious/qif/reader/invoice"
 	"github.com/maloquacious/qif/reader/security"
 	"github.com/maloquacious/qif/reader/tag"
 	"github.com/maloquacious/qif/reader/transaction"
 	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
+	"unicode/utf8"
 )
 
 type Reader struct {
@@ -50,109 +60,474 @@ type Reader struct {
 	Categories   *category.Section     `json:"categories,omitempty"`
 	Securities   *security.Section     `json:"securities,omitempty"`
 	Tags         *tag.Section          `json:"tags,omitempty"`
+	Classes      *class.Section        `json:"classes,omitempty"`
+	Bills        []*invoice.Record     `json:"bills,omitempty"`
+	Invoices     []*invoice.Record     `json:"invoices,omitempty"`
 	Transactions []*transaction.Record `json:"transactions,omitempty"`
 	Memorized    []*transaction.Record `json:"-"`
 	Prices       []*transaction.Record `json:"-"`
+	// Unknown holds the sections that weren't recognized. It is only
+	// populated when reading with Options.Passthrough.
+	Unknown []*RawSection `json:"unknown,omitempty"`
+	// Dates is the date format that the input was read with. It is never
+	// stdlib.DateAuto since the format is detected before reading.
+	Dates stdlib.DateOptions `json:"-"`
+}
+
+// ErrUnsupportedAccountType is returned when a transaction section or a
+// writer finds an account type that it doesn't know how to handle.
+var ErrUnsupportedAccountType = transaction.ErrUnsupportedAccountType
+
+// Options controls how Read parses the input.
+type Options struct {
+	// Lenient changes Read so that it doesn't stop at the first malformed
+	// record. Instead, it records a Diagnostic, skips to the next record
+	// terminator, and keeps parsing.
+	Lenient bool
+
+	// Dates is the format of the dates in the input. With stdlib.DateAuto,
+	// the format is detected from all the dates in the input.
+	Dates stdlib.DateOptions
+
+	// Workers is the number of goroutines that parse transaction sections.
+	// Other sections are always parsed in order since they set up the
+	// context that the transaction sections need. With 0 or 1, everything
+	// is parsed on the calling goroutine. The results are the same either
+	// way.
+	Workers int
+
+	// Passthrough keeps input that would otherwise be rejected. Fields
+	// with unknown codes are saved in the Extra map of the record and
+	// unknown sections are saved as RawSections.
+	Passthrough bool
+}
+
+// RawSection is a section that the reader doesn't recognize. Lines holds
+// the input verbatim, including record terminators, so that the section
+// can be written back out unchanged.
+type RawSection struct {
+	Line   int      `json:"line"`
+	Header string   `json:"header"`
+	Lines  []string `json:"lines,omitempty"`
+}
+
+// Diagnostic describes a malformed record found in lenient mode.
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Section string `json:"section,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Section == "" {
+		return fmt.Sprintf("%d:%d: %s", d.Line, d.Col, d.Reason)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Col, d.Section, d.Reason)
+}
+
+// ParseError is returned by Read when the input is malformed.
+// Err is the underlying error, if there is one.
+type ParseError struct {
+	Line    int
+	Col     int
+	Section string
+	Reason  string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	if e.Section == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Reason)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Col, e.Section, e.Reason)
 }
 
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Read returns the first error found in the input.
 func Read(sc scanner.Scanner) (*Reader, error) {
+	r, _, err := ReadWithOptions(sc, Options{})
+	return r, err
+}
+
+// ReadWithOptions is like Read, but in lenient mode it returns all of the
+// records that it could parse along with diagnostics for the ones that it
+// couldn't.
+func ReadWithOptions(sc scanner.Scanner, opts Options) (*Reader, []Diagnostic, error) {
 	var r Reader
-	for len(sc.Buffer) != 0 {
-		if literal, bb := sc.Literal("!Clear:AutoSwitch"); literal != nil {
-			// ignore
-			sc = bb
-			continue
+	p := parser{lenient: opts.Lenient, passthrough: opts.Passthrough, workers: opts.Workers}
+	sc.Dates, sc.Passthrough = opts.Dates, opts.Passthrough
+	if sc.Dates.Format == stdlib.DateAuto {
+		sc.Dates.Format = stdlib.DetectDateFormat(dateSamples(sc.Buffer), opts.Dates)
+	}
+	r.Dates = sc.Dates
+
+	err := p.sections(sc, &r)
+	if len(p.jobs) != 0 {
+		// the jobs come before any error from the other sections
+		if err := p.run(&r); err != nil {
+			return nil, nil, err
 		}
-		if literal, bb := sc.Literal("!Option:AutoSwitch"); literal != nil {
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &r, p.diagnostics, nil
+}
+
+// sections reads the input one section at a time. In parallel mode,
+// transaction sections are saved as jobs instead of being parsed.
+func (p *parser) sections(sc scanner.Scanner, r *Reader) error {
+	for len(sc.Buffer) != 0 {
+		// read the header once and dispatch on it
+		line, bb := sc.ToEndOfLine()
+		header := string(bytes.TrimRight(line, " \t"))
+		accountName, accountType := "", ""
+		switch header {
+		case "!Clear:AutoSwitch", "!Option:AutoSwitch":
 			// ignore
 			sc = bb
 			continue
-		}
-		if section, bb, err := account.ReadSection(sc); err != nil {
-			return nil, err
-		} else if section != nil {
-			if len(section.Records) != 0 {
+		case "!Account":
+			section := &account.Section{Line: sc.Line, Col: sc.Col}
+			bb, err := p.records(bb, "accounts", section.Read)
+			if err != nil {
+				return err
+			}
+			if records := section.Records; len(records) != 0 {
 				if r.Accounts == nil {
 					r.Accounts = section
-				} else if len(section.Records) == 1 {
-					r.active.account = section.Records[0].Name
-					r.active.accountType = section.Records[0].Type
+				} else if len(records) == 1 {
+					r.active.account = records[0].Name
+					r.active.accountType = records[0].Type
 				} else {
-					panic("!")
+					// ignore the header in lenient mode
+					err := p.report(Diagnostic{Line: sc.Line, Col: sc.Col, Section: "accounts", Reason: fmt.Sprintf("found %d records in account header", len(records))}, nil)
+					if err != nil {
+						return err
+					}
 				}
 			}
 			sc = bb
 			continue
-		}
-		if section, bb, err := category.ReadSection(sc); err != nil {
-			return nil, err
-		} else if section != nil {
+		case "!Type:Cat":
+			section := &category.Section{Line: sc.Line, Col: sc.Col}
+			bb, err := p.records(bb, "categories", section.Read)
+			if err != nil {
+				return err
+			}
 			if len(section.Records) != 0 {
 				if r.Categories == nil {
 					r.Categories = section
 				} else {
-					panic("!")
+					// keep the records in lenient mode
+					if err := p.report(Diagnostic{Line: sc.Line, Col: sc.Col, Section: "categories", Reason: "duplicate section"}, nil); err != nil {
+						return err
+					}
+					r.Categories.Records = append(r.Categories.Records, section.Records...)
 				}
 			}
 			sc = bb
 			continue
-		}
-		if section, bb, err := security.ReadSection(sc); err != nil {
-			return nil, err
-		} else if section != nil {
+		case "!Type:Security":
+			section := &security.Section{Line: sc.Line, Col: sc.Col}
+			bb, err := p.records(bb, "securities", section.Read)
+			if err != nil {
+				return err
+			}
 			if len(section.Records) != 0 {
 				if r.Securities == nil {
-					r.Securities = &security.Section{
-						Line: sc.Line,
-						Col:  sc.Col,
-					}
+					r.Securities = section
+				} else {
+					r.Securities.Records = append(r.Securities.Records, section.Records...)
 				}
-				r.Securities.Records = append(r.Securities.Records, section.Records...)
 			}
 			sc = bb
 			continue
-		}
-		if section, bb, err := tag.ReadSection(sc); err != nil {
-			return nil, err
-		} else if section != nil {
+		case "!Type:Tag":
+			section := &tag.Section{Line: sc.Line, Col: sc.Col}
+			bb, err := p.records(bb, "tags", section.Read)
+			if err != nil {
+				return err
+			}
 			if len(section.Records) != 0 {
 				if r.Tags == nil {
 					r.Tags = section
 				} else {
-					panic("!")
+					// keep the records in lenient mode
+					if err := p.report(Diagnostic{Line: sc.Line, Col: sc.Col, Section: "tags", Reason: "duplicate section"}, nil); err != nil {
+						return err
+					}
+					r.Tags.Records = append(r.Tags.Records, section.Records...)
 				}
 			}
 			sc = bb
 			continue
-		}
-		if section, bb, err := transaction.ReadSection(sc, r.active.account, r.active.accountType); err != nil {
-			return nil, err
-		} else if section != nil {
-			for _, xact := range section.Records {
-				r.Transactions = append(r.Transactions, xact)
+		case "!Type:Class":
+			section := &class.Section{Line: sc.Line, Col: sc.Col}
+			bb, err := p.records(bb, "classes", section.Read)
+			if err != nil {
+				return err
+			}
+			if len(section.Records) != 0 {
+				if r.Classes == nil {
+					r.Classes = section
+				} else {
+					// keep the records in lenient mode
+					if err := p.report(Diagnostic{Line: sc.Line, Col: sc.Col, Section: "classes", Reason: "duplicate section"}, nil); err != nil {
+						return err
+					}
+					r.Classes.Records = append(r.Classes.Records, section.Records...)
+				}
+			}
+			sc = bb
+			continue
+		case "!Type:Bill", "!Type:Invoice":
+			typ, sname := "Bill", "bills"
+			if header == "!Type:Invoice" {
+				typ, sname = "Invoice", "invoices"
+			}
+			section := &invoice.Section{Line: sc.Line, Col: sc.Col, Type: typ}
+			bb, err := p.records(bb, sname, section.Read)
+			if err != nil {
+				return err
+			}
+			if typ == "Bill" {
+				r.Bills = append(r.Bills, section.Records...)
+			} else {
+				r.Invoices = append(r.Invoices, section.Records...)
 			}
 			sc = bb
 			continue
+		case "!Type:Memorized":
+			accountType, sc = "Memorized", bb
+		case "!Type:Prices":
+			accountType, sc = "Prices", bb
+		default:
+			if r.active.accountType != "" && header == "!Type:"+r.active.accountType {
+				switch r.active.accountType {
+				case "Bank", "Cash", "CCard", "Invst", "Oth A", "Oth L":
+					accountName, accountType, sc = r.active.account, r.active.accountType, bb
+				default:
+					// skip the section in lenient mode
+					err := p.report(Diagnostic{Line: sc.Line, Col: sc.Col, Section: "transactions", Reason: fmt.Sprintf("unsupported account type %q", r.active.accountType)}, ErrUnsupportedAccountType)
+					if err != nil {
+						return err
+					}
+					sc = skipSection(sc)
+					continue
+				}
+			}
 		}
-		if section, bb, err := transaction.ReadSection(sc, "", "Memorized"); err != nil {
-			return nil, err
-		} else if section != nil {
-			for _, xact := range section.Records {
-				r.Memorized = append(r.Memorized, xact)
+		if accountType != "" && p.workers > 1 {
+			p.jobs = append(p.jobs, &job{sc: sc, accountName: accountName, accountType: accountType, diagnostics: len(p.diagnostics)})
+			sc = nextSection(sc)
+			continue
+		} else if accountType != "" {
+			section := &transaction.Section{Line: sc.Line, Col: sc.Col, Account: accountName, AccountType: accountType}
+			bb, err := p.records(sc, "transactions", section.Read)
+			if err != nil {
+				return err
+			}
+			switch accountType {
+			case "Memorized":
+				r.Memorized = append(r.Memorized, section.Records...)
+			case "Prices":
+				r.Prices = append(r.Prices, section.Records...)
+			default:
+				r.Transactions = append(r.Transactions, section.Records...)
 			}
 			sc = bb
 			continue
 		}
-		if section, bb, err := transaction.ReadSection(sc, "", "Prices"); err != nil {
-			return nil, err
-		} else if section != nil {
-			for _, xact := range section.Records {
-				r.Prices = append(r.Prices, xact)
+		if p.passthrough {
+			var raw *RawSection
+			raw, sc = rawSection(sc)
+			r.Unknown = append(r.Unknown, raw)
+			continue
+		}
+		if err := p.report(Diagnostic{Line: sc.Line, Col: sc.Col, Reason: fmt.Sprintf("unknown section %q", line)}, nil); err != nil {
+			return err
+		}
+		sc = skipSection(sc)
+	}
+	return nil
+}
+
+// rawSection consumes input up to the start of the next section and
+// returns it unchanged.
+func rawSection(sc scanner.Scanner) (*RawSection, scanner.Scanner) {
+	raw := &RawSection{Line: sc.Line}
+	header, sc := sc.ToEndOfLine()
+	raw.Header = string(header)
+	for len(sc.Buffer) != 0 && sc.Buffer[0] != '!' {
+		var line []byte
+		line, sc = sc.ToEndOfLine()
+		raw.Lines = append(raw.Lines, string(line))
+	}
+	return raw, sc
+}
+
+// parser holds the state that is shared by all the sections.
+type parser struct {
+	lenient     bool
+	passthrough bool
+	workers     int
+	diagnostics []Diagnostic
+	jobs        []*job // transaction sections to parse in parallel mode
+}
+
+// report returns the diagnostic as a *ParseError in strict mode.
+// In lenient mode, it saves the diagnostic and returns nil.
+func (p *parser) report(d Diagnostic, err error) error {
+	if !p.lenient {
+		return &ParseError{Line: d.Line, Col: d.Col, Section: d.Section, Reason: d.Reason, Err: err}
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	return nil
+}
+
+// records calls read for each record in the section, then stops at the
+// end of section marker. The read function returns false when it doesn't
+// find a record.
+//
+// Malformed records are reported. In lenient mode, they are skipped,
+// except for records that are only missing their terminator.
+func (p *parser) records(sc scanner.Scanner, sname string, read func(scanner.Scanner) (bool, scanner.Scanner, error)) (scanner.Scanner, error) {
+	for {
+		if eos, _ := sc.EndOfSection(); eos != nil {
+			return sc, nil
+		}
+		found, bb, err := read(sc)
+		if err != nil && found {
+			// the record is only missing its terminator, so resume at the
+			// field that starts the next record
+			if err := p.report(unterminated(sname, bb), err); err != nil {
+				return sc, err
+			}
+			sc = bb
+			continue
+		} else if err != nil {
+			if err := p.report(malformed(sname, bb, err), err); err != nil {
+				return sc, err
 			}
+			sc = skipRecord(bb)
+			continue
+		} else if found {
 			sc = bb
 			continue
 		}
-		return nil, fmt.Errorf("%d:%d: unexpected input", sc.Line, sc.Col)
+
+		// no record was found and this isn't the end of the section
+		if err := p.report(unexpected(sname, sc), nil); err != nil {
+			return sc, err
+		}
+		if eol, bb := sc.EndOfLine(); eol != nil {
+			sc = bb
+		} else if unknown, _ := unknownField(sname, sc); unknown != "" {
+			sc = skipRecord(sc)
+		} else {
+			_, sc = sc.ToEndOfLine()
+		}
+	}
+}
+
+// unterminated describes a record that the parser kept even though it
+// stopped at a field that starts the next record.
+func unterminated(sname string, stop scanner.Scanner) Diagnostic {
+	return Diagnostic{Line: stop.Line, Col: stop.Col, Section: sname, Reason: "missing record terminator"}
+}
+
+// malformed describes an error returned by a record parser.
+// The parser stops at the position where it found the problem.
+func malformed(sname string, stop scanner.Scanner, err error) Diagnostic {
+	if unknown, ok := unknownField(sname, stop); ok {
+		// an unknown field stops the record parser, so it is the root cause
+		return Diagnostic{Line: stop.Line, Col: stop.Col, Section: sname, Reason: unknown}
+	}
+	var serr *scanner.Error
+	if errors.As(err, &serr) {
+		return Diagnostic{Line: serr.Line, Col: serr.Col, Section: sname, Reason: serr.Reason}
+	}
+	return Diagnostic{Line: stop.Line, Col: stop.Col, Section: sname, Reason: err.Error()}
+}
+
+// unexpected describes input where a record was expected.
+func unexpected(sname string, sc scanner.Scanner) Diagnostic {
+	if eol, _ := sc.EndOfLine(); eol != nil {
+		return Diagnostic{Line: sc.Line, Col: sc.Col, Section: sname, Reason: "unexpected blank line"}
+	} else if unknown, ok := unknownField(sname, sc); ok {
+		return Diagnostic{Line: sc.Line, Col: sc.Col, Section: sname, Reason: unknown}
+	}
+	return Diagnostic{Line: sc.Line, Col: sc.Col, Section: sname, Reason: "empty record"}
+}
+
+// dateSamples returns the values of every line that could be a date.
+func dateSamples(buf []byte) []string {
+	var samples []string
+	for len(buf) != 0 {
+		line := buf
+		if eol := bytes.IndexByte(buf, '\n'); eol != -1 {
+			line, buf = buf[:eol], buf[eol+1:]
+		} else {
+			buf = nil
+		}
+		if len(line) != 0 && (line[0] == 'D' || line[0] == '/') {
+			// values that aren't dates don't count for any format
+			samples = append(samples, string(line[1:]))
+		}
+	}
+	return samples
+}
+
+// unknownField returns a reason if the scanner is positioned at a line
+// that isn't a blank line, a record terminator or a section header.
+// Dates that can't be parsed are reported as invalid rather than unknown.
+func unknownField(sname string, sc scanner.Scanner) (string, bool) {
+	if len(sc.Buffer) == 0 {
+		return "", false
+	}
+	switch sc.Buffer[0] {
+	case '\n', '^', '!':
+		return "", false
+	}
+	r, w := utf8.DecodeRune(sc.Buffer)
+	if ((sname == "transactions" || sname == "bills" || sname == "invoices") && r == 'D') || (sname == "accounts" && r == '/') {
+		value, _ := sc.ToEndOfLine()
+		if _, err := sc.Dates.Parse(string(value[w:])); err != nil {
+			return fmt.Sprintf("invalid date %q", value[w:]), true
+		}
+	}
+	return fmt.Sprintf("unknown field code %q", r), true
+}
+
+// skipRecord consumes input up to and including the next record terminator.
+// It stops early at the start of a new section.
+func skipRecord(sc scanner.Scanner) scanner.Scanner {
+	for len(sc.Buffer) != 0 && sc.Buffer[0] != '!' {
+		stop := sc.Buffer[0] == '^'
+		_, sc = sc.ToEndOfLine()
+		if stop {
+			break
+		}
+	}
+	return sc
+}
+
+// skipSection consumes input up to the start of the next section.
+func skipSection(sc scanner.Scanner) scanner.Scanner {
+	_, sc = sc.ToEndOfLine()
+	return nextSection(sc)
+}
+
+// nextSection consumes input up to the next section header. Unlike
+// skipSection, it doesn't skip the current line if it is a header.
+func nextSection(sc scanner.Scanner) scanner.Scanner {
+	for len(sc.Buffer) != 0 && sc.Buffer[0] != '!' {
+		_, sc = sc.ToEndOfLine()
 	}
-	return &r, nil
+	return sc
 }
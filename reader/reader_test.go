@@ -0,0 +1,499 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reader_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLenient(t *testing.T) {
+	// Specification: Lenient mode
+
+	// Given a QIF file with malformed records
+	input := `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T-54.25
+^
+T-10.00
+PMissing Date
+^
+D1/ 5'16
+T-20.00
+XUnknown
+^
+D1/ 6'16
+T-30.00
+!Type:Foo
+D1/ 7'16
+^
+!Type:Memorized
+KP
+T-54.25
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When it is read in strict mode
+	// Then it returns an error
+	if _, err := reader.Read(sc); err == nil {
+		t.Errorf("strict: expected error: yields nil\n")
+	}
+
+	// When it is read in lenient mode
+	r, diagnostics, err := reader.ReadWithOptions(sc, reader.Options{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then it reports every malformed record
+	expected := []reader.Diagnostic{
+		{Line: 15, Col: 1, Section: "transactions", Reason: `missing field "date"`},
+		{Line: 20, Col: 1, Section: "transactions", Reason: `unknown field code 'X'`},
+		{Line: 24, Col: 1, Section: "transactions", Reason: "missing record terminator"},
+		{Line: 24, Col: 1, Reason: `unknown section "!Type:Foo"`},
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("diagnostics: expected %d: yields %d: %v\n", len(expected), len(diagnostics), diagnostics)
+	}
+	for i := range expected {
+		if expected[i] != diagnostics[i] {
+			t.Errorf("diagnostic %d: expected %q: yields %q\n", i, expected[i], diagnostics[i])
+		}
+	}
+
+	// And it returns the records that it could parse
+	if len(r.Transactions) != 1 || r.Transactions[0].AmountTCode != "-54.25" {
+		t.Errorf("transactions: expected 1 record: yields %d\n", len(r.Transactions))
+	}
+	if len(r.Memorized) != 1 {
+		t.Errorf("memorized: expected 1 record: yields %d\n", len(r.Memorized))
+	}
+}
+
+func TestMissingTerminator(t *testing.T) {
+	// Specification: Records without a terminator
+
+	// Given three transactions where the second is missing its terminator
+	input := "!Account\nNChecking\nTBank\n^\n!Account\nNChecking\nTBank\n^\n!Type:Bank\n" +
+		"D1/ 4'16\nT-54.25\n^\n" +
+		"D1/ 5'16\nT-20.00\n" +
+		"D1/ 6'16\nT-30.00\n^\n"
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When it is read in strict mode
+	// Then it reports the missing terminator
+	var perr *reader.ParseError
+	if _, err := reader.Read(sc); !errors.As(err, &perr) {
+		t.Fatalf("strict: expected *ParseError: yields %v\n", err)
+	} else if perr.Line != 15 || perr.Reason != "missing record terminator" {
+		t.Errorf("strict: yields %d: %q: expected 15: %q\n", perr.Line, perr.Reason, "missing record terminator")
+	}
+
+	// When it is read in lenient mode
+	r, diagnostics, err := reader.ReadWithOptions(sc, reader.Options{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then it reports the missing terminator once
+	expected := []reader.Diagnostic{{Line: 15, Col: 1, Section: "transactions", Reason: "missing record terminator"}}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("diagnostics: yields %v: expected %v\n", diagnostics, expected)
+	}
+
+	// And it keeps all three records
+	var amounts []string
+	for _, record := range r.Transactions {
+		amounts = append(amounts, record.AmountTCode)
+	}
+	if yields, expected := strings.Join(amounts, " "), "-54.25 -20.00 -30.00"; yields != expected {
+		t.Errorf("transactions: yields %q: expected %q\n", yields, expected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	// Specification: Errors
+
+	// When a file has a second category section
+	// Then it returns a *ParseError
+	input := "!Type:Cat\nNSalary\nI\n^\n!Type:Cat\nNRent\nE\n^\n"
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var perr *reader.ParseError
+	if _, err = reader.Read(sc); !errors.As(err, &perr) {
+		t.Errorf("duplicate section: expected *ParseError: yields %v\n", err)
+	} else if perr.Line != 5 || perr.Section != "categories" {
+		t.Errorf("duplicate section: expected 5:categories: yields %d:%s\n", perr.Line, perr.Section)
+	}
+
+	// When a transaction section is for an unsupported account type
+	// Then it returns ErrUnsupportedAccountType
+	input = "!Account\nNChecking\nTBank\n^\n!Account\nNStocks\nTPort\n^\n!Type:Port\nD1/ 4'16\n^\n"
+	if sc, err = scanner.New([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = reader.Read(sc); !errors.Is(err, reader.ErrUnsupportedAccountType) {
+		t.Errorf("unsupported account type: expected ErrUnsupportedAccountType: yields %v\n", err)
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	// Specification: Date formats
+
+	// Given a file with European dates
+	input := "!Account\nNChecking\nTBank\n^\n!Account\nNChecking\nTBank\n^\n!Type:Bank\nD1/2/16\nT-1.00\n^\nD31/12/16\nT-2.00\n^\n"
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When it is read with the default options
+	// Then the format is detected from all the dates
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Transactions[0].Date.String() != "2016-02-01" || r.Transactions[1].Date.String() != "2016-12-31" {
+		t.Errorf("auto: yields %s, %s: expected %s, %s\n", r.Transactions[0].Date, r.Transactions[1].Date, "2016-02-01", "2016-12-31")
+	}
+
+	// When it is read as US dates
+	// Then it returns an error
+	if _, _, err := reader.ReadWithOptions(sc, reader.Options{Dates: stdlib.DateOptions{Format: stdlib.DateUS}}); err == nil {
+		t.Errorf("us: expected error: yields nil\n")
+	}
+}
+
+func TestListSections(t *testing.T) {
+	// Specification: Class, bill and invoice sections
+
+	// Given a file with a class list, a bill and an invoice
+	input := `!Type:Class
+NBusiness
+DSelf employment
+^
+NPersonal
+^
+!Type:Bill
+D1/ 4'16
+N1001
+PAcme Supply
+T-250.00
+XE2/ 3'16
+^
+!Type:Invoice
+D1/ 5'16
+N2001
+PWidget Corp
+T1,080.00
+XI1
+XE2/ 4'16
+XR8.00
+XT80.00
+XSConsulting
+XNConsulting Income
+X#10
+X$100.00
+XFT
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When it is read
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then the classes are in the class list
+	if r.Classes == nil || len(r.Classes.Records) != 2 || r.Classes.Records[0].Description != "Self employment" {
+		t.Errorf("classes: expected 2 records: yields %+v\n", r.Classes)
+	}
+
+	// And the bill and invoice have their own records
+	if len(r.Bills) != 1 || r.Bills[0].Payee != "Acme Supply" || r.Bills[0].DueDate.String() != "2016-02-03" {
+		t.Errorf("bills: expected 1 record due 2016-02-03: yields %d\n", len(r.Bills))
+	}
+	if len(r.Invoices) != 1 || len(r.Invoices[0].Items) != 1 {
+		t.Fatalf("invoices: expected 1 record with 1 item: yields %d\n", len(r.Invoices))
+	}
+	item := r.Invoices[0].Items[0]
+	if item.Description != "Consulting" || item.Category != "Consulting Income" || item.Quantity != "10" || item.Price != "100.00" || !item.Taxable {
+		t.Errorf("invoice item: yields %+v\n", *item)
+	}
+
+	// And the decoder returns the same records
+	d := reader.NewDecoder(strings.NewReader(input))
+	var items int
+	for {
+		if _, err := d.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		items++
+	}
+	if items != 4 {
+		t.Errorf("decoder: expected 4 items: yields %d\n", items)
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	// Specification: Passthrough
+
+	// Given a file with an unknown field and an unknown section
+	input := `!Account
+NChecking
+TBank
+^
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 4'16
+T-54.25
+PGrocer
+ZReceipt 1
+ZReceipt 2
+^
+!Type:Foo
+Qbar
+^
+`
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When it is read without passthrough
+	// Then it returns an error
+	if _, err := reader.Read(sc); err == nil {
+		t.Errorf("strict: expected error: yields nil\n")
+	}
+
+	// When it is read with passthrough
+	r, _, err := reader.ReadWithOptions(sc, reader.Options{Passthrough: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then the unknown field is kept on the record
+	if len(r.Transactions) != 1 {
+		t.Fatalf("transactions: expected 1 record: yields %d\n", len(r.Transactions))
+	}
+	if extra := r.Transactions[0].Extra["Z"]; len(extra) != 2 || extra[0] != "Receipt 1" || extra[1] != "Receipt 2" {
+		t.Errorf("extra: expected [Receipt 1 Receipt 2]: yields %q\n", extra)
+	}
+
+	// And the unknown section is kept as a raw section
+	if len(r.Unknown) != 1 || r.Unknown[0].Line != 16 || r.Unknown[0].Header != "!Type:Foo" {
+		t.Fatalf("unknown: expected 1 section on line 16: yields %+v\n", r.Unknown)
+	}
+	if lines := r.Unknown[0].Lines; len(lines) != 2 || lines[0] != "Qbar" || lines[1] != "^" {
+		t.Errorf("unknown: expected [Qbar ^]: yields %q\n", lines)
+	}
+
+	// And the decoder returns the same records
+	d := reader.NewDecoder(strings.NewReader(input))
+	d.Passthrough = true
+	var raw *reader.RawSection
+	var extra int
+	for {
+		item, err := d.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		switch item := item.(type) {
+		case *reader.RawSection:
+			raw = item
+		case *transaction.Record:
+			extra = len(item.Extra["Z"])
+		}
+	}
+	if extra != 2 {
+		t.Errorf("decoder: extra: expected 2 values: yields %d\n", extra)
+	}
+	if raw == nil || raw.Header != "!Type:Foo" || len(raw.Lines) != 2 {
+		t.Errorf("decoder: unknown: expected !Type:Foo: yields %+v\n", raw)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	// Specification: Source positions
+
+	// Given a transaction with a split after a multi-byte payee
+	input := "!Account\nNChecking\nTBank\n^\n!Account\nNChecking\nTBank\n^\n!Type:Bank\nD1/ 4'16\nPCafé\nT-54.25\nSDining\n$-54.25\n^\n"
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When it is read
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Transactions) != 1 || len(r.Transactions[0].Split) != 1 {
+		t.Fatalf("transactions: expected 1 record with 1 split: yields %d\n", len(r.Transactions))
+	}
+	record, split := r.Transactions[0], r.Transactions[0].Split[0]
+
+	// Then the record span covers its lines, including the terminator
+	if yields, expected := string(record.Span.Text(sc.Buffer)), "D1/ 4'16\nPCafé\nT-54.25\nSDining\n$-54.25\n^\n"; yields != expected {
+		t.Errorf("record span yields %q: expected %q\n", yields, expected)
+	}
+	if start := record.Span.Start; start.Line != 10 || start.Col != 1 || record.Line != 10 {
+		t.Errorf("record start yields %+v: expected line 10, col 1\n", start)
+	}
+	if end := record.Span.End; end.Line != 16 || end.Col != 1 || end.Offset != len(input) {
+		t.Errorf("record end yields %+v: expected line 16, col 1, offset %d\n", end, len(input))
+	}
+
+	// And the split span covers only the split lines
+	if yields, expected := string(split.Span.Text(sc.Buffer)), "SDining\n$-54.25\n"; yields != expected {
+		t.Errorf("split span yields %q: expected %q\n", yields, expected)
+	}
+	if split.Line != 13 || split.Col != 1 {
+		t.Errorf("split yields %d:%d: expected 13:1\n", split.Line, split.Col)
+	}
+
+	// And columns count runes rather than bytes
+	_, bb := sc.Literal("!Account")
+	if bb.Line != 2 || bb.Col != 1 || bb.Offset != len("!Account\n") {
+		t.Errorf("literal yields %+v: expected line 2, col 1\n", bb.Pos())
+	}
+	sc, err = scanner.New([]byte("PCafé\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, bb = sc.Field("P"); bb.Line != 2 || bb.Offset != len("PCafé\n") {
+		t.Errorf("field yields %+v: expected line 2\n", bb.Pos())
+	}
+	sc.Buffer = sc.Buffer[:len("PCafé")]
+	if _, bb = sc.ToEndOfLine(); bb.Col != 6 {
+		t.Errorf("col yields %d: expected 6\n", bb.Col)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	// Specification: Parallel section parsing
+
+	// Given a file with several accounts, an unknown section between them
+	// and a malformed record in two of the transaction sections
+	input := strings.Join([]string{
+		"!Option:AutoSwitch",
+		"!Account", "NChecking", "TBank", "^", "NSavings", "TBank", "^", "NVisa", "TCCard", "^",
+		"!Clear:AutoSwitch",
+		"!Account", "NChecking", "TBank", "^",
+		"!Type:Bank",
+		"D1/ 4'16", "PGrocer", "T-54.25", "^",
+		"D1/ 5'16", "X?", "T-1.00", "^",
+		"D1/ 6'16", "PPaycheck", "T1000.00", "^",
+		"!Type:Widgets",
+		"NSprocket", "^",
+		"!Account", "NSavings", "TBank", "^",
+		"!Type:Bank",
+		"D1/ 7'16", "PTransfer", "T100.00", "^",
+		"!Account", "NVisa", "TCCard", "^",
+		"!Type:CCard",
+		"D1/ 8'16", "PDiner", "T-12.50", "^",
+		"D1/ 9'16", "Z?", "^",
+		"!Type:Memorized",
+		"KC", "PGrocer", "T-54.25", "^",
+		"",
+	}, "\n")
+
+	for _, lenient := range []bool{true, false} {
+		sc, err := scanner.New([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// When it is read sequentially and with workers
+		r1, d1, err1 := reader.ReadWithOptions(sc, reader.Options{Lenient: lenient})
+		r2, d2, err2 := reader.ReadWithOptions(sc, reader.Options{Lenient: lenient, Workers: 4})
+
+		// Then the results are the same
+		if fmt.Sprint(err1) != fmt.Sprint(err2) {
+			t.Errorf("lenient %v: error yields %v: expected %v\n", lenient, err2, err1)
+		}
+		if !reflect.DeepEqual(r1, r2) {
+			t.Errorf("lenient %v: reader yields %+v: expected %+v\n", lenient, r2, r1)
+		}
+		if !reflect.DeepEqual(d1, d2) {
+			t.Errorf("lenient %v: diagnostics yields %+v: expected %+v\n", lenient, d2, d1)
+		}
+
+		// And lenient mode reports every problem in file order
+		if lenient {
+			if len(r2.Transactions) != 4 || len(r2.Memorized) != 1 {
+				t.Errorf("transactions yields %d, %d: expected 4, 1\n", len(r2.Transactions), len(r2.Memorized))
+			}
+			var lines []int
+			for _, d := range d2 {
+				lines = append(lines, d.Line)
+			}
+			if len(lines) != 3 || !sort.IntsAreSorted(lines) {
+				t.Errorf("diagnostics yields %v: expected 3 lines in order\n", lines)
+			}
+		} else if err2 == nil {
+			t.Errorf("strict yields no error: expected the first malformed record\n")
+		}
+	}
+}
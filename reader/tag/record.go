@@ -30,10 +30,12 @@ import (
 )
 
 type Record struct {
-	Line        int    `json:"-"`
-	Col         int    `json:"-"`
-	Description string `json:"descr,omitempty"`
-	Name        string `json:"name"`
+	Line        int                 `json:"-"`
+	Col         int                 `json:"-"`
+	Span        scanner.Span        `json:"-"` // source lines, including the terminator
+	Description string              `json:"descr,omitempty"`
+	Name        string              `json:"name"`
+	Extra       map[string][]string `json:"extra,omitempty"` // unknown fields, by code
 }
 
 func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
@@ -55,6 +57,17 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 			}
 		}
 
+		if sc.Passthrough {
+			if code, value, bb := sc.Unknown(); value != nil {
+				if record.Extra == nil {
+					record.Extra = make(map[string][]string)
+				}
+				found, record.Extra[code] = true, append(record.Extra[code], string(value))
+				sc = bb
+				continue
+			}
+		}
+
 		break
 	}
 
@@ -64,14 +77,21 @@ func ReadRecord(sc scanner.Scanner) (*Record, scanner.Scanner, error) {
 
 	// check for required fields
 	if name == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing field %q", record.Line, sname, "name")
+		return nil, sc, &scanner.Error{Line: record.Line, Col: record.Col, Record: sname, Reason: fmt.Sprintf("missing field %q", "name")}
 	}
 
 	eor, bb := sc.EndOfRecord()
 	if eor == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing record terminator", sc.Line, sname)
+		err := &scanner.Error{Line: sc.Line, Col: sc.Col, Record: sname, Reason: "missing record terminator"}
+		if rpt, _ := sc.Repeat(saved); rpt != nil {
+			// the field starts the next record, so keep this one
+			record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
+			return &record, sc, err
+		}
+		return nil, sc, err
 	}
 	sc = bb
+	record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
 
 	return &record, sc, nil
 }
@@ -34,15 +34,16 @@ import (
 type Record struct {
 	Line          int
 	Col           int
+	Span          scanner.Span // source lines, including the terminator
 	Account       string
 	Address       []string // Up to five lines (the sixth line is an optional message)
 	AmountTCode   string
 	AmountUCode   string
-	BudgetAmount  []string
-	Category      string // Category/Subcategory/Transfer/Class
+	BudgetAmount  []string // memorized loan fields, indexed by line code minus one
+	Category      string   // Category/Subcategory/Transfer/Class
 	ClearedStatus string
 	Commission    string
-	Date          string
+	Date          stdlib.CivilDate
 	Interest      string
 	Memo          string
 	MemorizedFlag string
@@ -54,166 +55,215 @@ type Record struct {
 	Ticker        string
 	ToAccount     string // if category is [xxxx], then ToAccount is 'xxxx'
 	Type          string
+	Extra         map[string][]string // unknown fields, by code
 }
 
 type Split struct {
-	Line     int    `json:"-"`
-	Col      int    `json:"-"`
-	Account  string `json:"account,omitempty"`
-	Amount   string `json:"amount,omitempty"`
-	Category string `json:"category,omitempty"`
-	Memo     string `json:"memo,omitempty"`
+	Line     int          `json:"-"`
+	Col      int          `json:"-"`
+	Span     scanner.Span `json:"-"`
+	Account  string       `json:"account,omitempty"`
+	Amount   string       `json:"amount,omitempty"`
+	Category string       `json:"category,omitempty"`
+	Memo     string       `json:"memo,omitempty"`
+	Implied  bool         `json:"implied,omitempty"` // started by an E or $ line instead of an S line
 }
 
 func ReadRecord(sc scanner.Scanner, account, accountType string) (*Record, scanner.Scanner, error) {
 	saved, sname, record := sc, "transaction", Record{Line: sc.Line, Col: sc.Col, Account: account, Type: accountType}
 
 	var found bool
-	var category, cleared, commission, date, interest, memo, memorized, payee, qty, refNo, ticker, tcode, toAccount, ucode []byte
+	var date *stdlib.CivilDate
+	var category, cleared, commission, interest, memo, memorized, payee, qty, refNo, ticker, tcode, toAccount, ucode []byte
 	var split *Split
-	for {
-		if addrLine, bb := sc.Field("A"); addrLine != nil {
-			found, record.Address = true, append(record.Address, string(addrLine))
-			sc = bb
-			continue
-		}
-		if cleared == nil {
-			if cleared, sc = sc.Field("C"); cleared != nil {
-				found, record.ClearedStatus = true, string(cleared)
+
+	// transactions are most of the input, so look up the field code once
+	// instead of trying each field in turn.
+	for len(sc.Buffer) != 0 {
+		switch sc.Buffer[0] {
+		case 'A':
+			if addrLine, bb := sc.Field("A"); addrLine != nil {
+				found, record.Address = true, append(record.Address, string(addrLine))
+				sc = bb
 				continue
 			}
-		}
-		if commission == nil {
-			if commission, sc = sc.Field("O"); commission != nil {
-				found, record.Commission = true, string(commission)
-				continue
+		case 'C':
+			if cleared == nil {
+				if cleared, sc = sc.Field("C"); cleared != nil {
+					found, record.ClearedStatus = true, string(cleared)
+					continue
+				}
 			}
-		}
-		if date == nil {
-			if date, sc = sc.Date("D"); date != nil {
-				found, record.Date = true, string(date)
-				continue
+		case 'O':
+			if commission == nil {
+				if commission, sc = sc.Field("O"); commission != nil {
+					found, record.Commission = true, string(commission)
+					continue
+				}
 			}
-		}
-		if interest == nil {
-			if interest, sc = sc.Field("I"); interest != nil {
-				found, record.Interest = true, string(interest)
-				continue
+		case 'D':
+			if date == nil {
+				if date, sc = sc.Date("D"); date != nil {
+					found, record.Date = true, *date
+					continue
+				}
 			}
-		}
-		if memo == nil {
-			if memo, sc = sc.Field("M"); memo != nil {
-				found, record.Memo = true, string(memo)
-				continue
+		case 'I':
+			if interest == nil {
+				if interest, sc = sc.Field("I"); interest != nil {
+					found, record.Interest = true, string(interest)
+					continue
+				}
 			}
-		}
-		if memorized == nil {
-			if memorized, sc = sc.Field("K"); memorized != nil {
-				found, record.MemorizedFlag = true, string(memorized)
-				continue
+		case 'M':
+			if memo == nil {
+				if memo, sc = sc.Field("M"); memo != nil {
+					found, record.Memo = true, string(memo)
+					continue
+				}
 			}
-		}
-		if payee == nil {
-			if payee, sc = sc.Field("P"); payee != nil {
-				found, record.Payee = true, string(payee)
-				continue
+		case 'K':
+			if memorized == nil {
+				if memorized, sc = sc.Field("K"); memorized != nil {
+					found, record.MemorizedFlag = true, string(memorized)
+					continue
+				}
 			}
-		}
-		if price, bb := sc.Field("\""); price != nil {
-			lexeme := strings.TrimRight(string(price), "\"")
-			if fields := strings.Split(lexeme, "\""); len(fields) == 3 {
-				found, record.Ticker = true, fields[0]
-				record.Price = strings.ReplaceAll(fields[1], ",", "")
-				date = []byte(fields[2])
-				record.Date = stdlib.Date(date)
+		case 'P':
+			if payee == nil {
+				if payee, sc = sc.Field("P"); payee != nil {
+					found, record.Payee = true, string(payee)
+					continue
+				}
+			}
+		case '"':
+			if price, bb := sc.Field("\""); price != nil {
+				lexeme := strings.TrimRight(string(price), "\"")
+				if fields := strings.Split(lexeme, "\""); len(fields) == 3 {
+					found, record.Ticker = true, fields[0]
+					record.Price = strings.ReplaceAll(fields[1], ",", "")
+					// an invalid price date is left as the zero date
+					record.Date, _ = sc.Dates.Parse(fields[2])
+					date = &record.Date
+					sc = bb
+					continue
+				}
+			}
+		case 'Q':
+			if qty == nil {
+				if qty, sc = sc.Field("Q"); qty != nil {
+					found, record.Quantity = true, string(qty)
+					continue
+				}
+			}
+		case 'N':
+			if refNo == nil {
+				if refNo, sc = sc.Field("N"); refNo != nil {
+					found, record.RefNo = true, string(refNo)
+					continue
+				}
+			}
+		case '$':
+			if splitAmount, bb := sc.Field("$"); splitAmount != nil {
+				// a repeated amount starts a new line
+				if split == nil || split.Amount != "" {
+					split = &Split{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}, Implied: true}
+					record.Split = append(record.Split, split)
+				}
+				found, split.Amount = true, string(splitAmount)
+				split.Span.End = bb.Pos()
 				sc = bb
 				continue
 			}
-		}
-		if qty == nil {
-			if qty, sc = sc.Field("Q"); qty != nil {
-				found, record.Quantity = true, string(qty)
+		case 'S':
+			if splitCategory, bb := sc.Field("S"); splitCategory != nil {
+				split = &Split{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}}
+				found, record.Split = true, append(record.Split, split)
+				split.Category = string(splitCategory)
+				if strings.HasPrefix(split.Category, "[") {
+					split.Account = strings.Trim(split.Category, "[]")
+					split.Category = ""
+				}
+				split.Span.End = bb.Pos()
+				sc = bb
 				continue
 			}
-		}
-		if refNo == nil {
-			if refNo, sc = sc.Field("N"); refNo != nil {
-				found, record.RefNo = true, string(refNo)
+		case 'E':
+			if splitMemo, bb := sc.Field("E"); splitMemo != nil {
+				// the memo comes before the amount, so a repeated memo or a
+				// memo after the amount starts a new line
+				if split == nil || split.Memo != "" || split.Amount != "" {
+					split = &Split{Line: sc.Line, Col: sc.Col, Span: scanner.Span{Start: sc.Pos()}, Implied: true}
+					record.Split = append(record.Split, split)
+				}
+				found, split.Memo = true, string(splitMemo)
+				split.Span.End = bb.Pos()
+				sc = bb
 				continue
 			}
-		}
-		if splitAmount, bb := sc.Field("$"); splitAmount != nil {
-			if split == nil {
-				split = &Split{Line: bb.Line}
-				record.Split = append(record.Split, split)
-			}
-			found, split.Amount = true, string(splitAmount)
-			sc = bb
-			continue
-		}
-		if splitCategory, bb := sc.Field("S"); splitCategory != nil {
-			split = &Split{Line: bb.Line}
-			found, record.Split = true, append(record.Split, split)
-			split.Category = string(splitCategory)
-			if strings.HasPrefix(split.Category, "[") {
-				split.Account = strings.Trim(split.Category, "[]")
-				split.Category = ""
-			}
-			sc = bb
-			continue
-		}
-		if splitMemo, bb := sc.Field("E"); splitMemo != nil {
-			if split == nil {
-				split = &Split{Line: bb.Line}
-				record.Split = append(record.Split, split)
-			}
-			found, split.Memo = true, string(splitMemo)
-			sc = bb
-			continue
-		}
-		if tcode == nil {
-			if tcode, sc = sc.Field("T"); tcode != nil {
-				found, record.AmountTCode = true, string(tcode)
-				continue
+		case 'T':
+			if tcode == nil {
+				if tcode, sc = sc.Field("T"); tcode != nil {
+					found, record.AmountTCode = true, string(tcode)
+					continue
+				}
 			}
-		}
-		if ticker == nil {
-			if ticker, sc = sc.Field("Y"); ticker != nil {
-				found, record.Ticker = true, string(ticker)
-				continue
+		case 'Y':
+			if ticker == nil {
+				if ticker, sc = sc.Field("Y"); ticker != nil {
+					found, record.Ticker = true, string(ticker)
+					continue
+				}
 			}
-		}
-		if ucode == nil {
-			if ucode, sc = sc.Field("U"); ucode != nil {
-				found, record.AmountUCode = true, string(ucode)
-				continue
+		case 'U':
+			if ucode == nil {
+				if ucode, sc = sc.Field("U"); ucode != nil {
+					found, record.AmountUCode = true, string(ucode)
+					continue
+				}
 			}
-		}
-
-		// category must follow toAccount since they share a common prefix
-		if toAccount == nil {
-			if toAccount, sc = sc.Field("L["); toAccount != nil {
-				found, record.ToAccount = true, strings.TrimRight(string(toAccount), "]")
-				continue
+		case 'L':
+			// category must follow toAccount since they share a common prefix
+			if toAccount == nil {
+				if toAccount, sc = sc.Field("L["); toAccount != nil {
+					found, record.ToAccount = true, strings.TrimRight(string(toAccount), "]")
+					continue
+				}
 			}
-		}
-		if category == nil {
-			if category, sc = sc.Field("L"); category != nil {
-				found, record.Category = true, string(category)
-				continue
+			if category == nil {
+				if category, sc = sc.Field("L"); category != nil {
+					found, record.Category = true, string(category)
+					continue
+				}
+			}
+		case '1', '2', '3', '4', '5', '6', '7':
+			// memorized loan fields are kept in the slot for their line code
+			// so that missing lines don't shift the ones that follow.
+			if record.Type == "Memorized" {
+				matched := false
+				for i, flag := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+					if budgetAmount, bb := sc.Field(flag); budgetAmount != nil {
+						for len(record.BudgetAmount) <= i {
+							record.BudgetAmount = append(record.BudgetAmount, "")
+						}
+						found, record.BudgetAmount[i], sc = true, string(budgetAmount), bb
+						matched = true
+						break
+					}
+				}
+				if matched {
+					continue
+				}
 			}
 		}
 
-		// add budget amount logic here
-		if record.Type == "Memorized" {
-			var budgetAmount []byte
-			for _, flag := range []string{"1", "2", "3", "4", "5", "6", "7"} {
-				if budgetAmount == nil {
-					budgetAmount, sc = sc.Field(flag)
+		if sc.Passthrough {
+			if code, value, bb := sc.Unknown(); value != nil {
+				if record.Extra == nil {
+					record.Extra = make(map[string][]string)
 				}
-			}
-			if budgetAmount != nil {
-				found, record.BudgetAmount = true, append(record.BudgetAmount, string(budgetAmount))
+				found, record.Extra[code] = true, append(record.Extra[code], string(value))
+				sc = bb
 				continue
 			}
 		}
@@ -229,19 +279,26 @@ func ReadRecord(sc scanner.Scanner, account, accountType string) (*Record, scann
 	switch record.Type {
 	case "Memorized":
 		if memorized == nil {
-			return nil, saved, fmt.Errorf("%d: %s: missing field %q", record.Line, sname, "memorized")
+			return nil, sc, &scanner.Error{Line: record.Line, Col: record.Col, Record: sname, Reason: fmt.Sprintf("missing field %q", "memorized")}
 		}
 	default:
 		if date == nil {
-			return nil, saved, fmt.Errorf("%d: %s: missing field %q", record.Line, sname, "date")
+			return nil, sc, &scanner.Error{Line: record.Line, Col: record.Col, Record: sname, Reason: fmt.Sprintf("missing field %q", "date")}
 		}
 	}
 
 	eor, bb := sc.EndOfRecord()
 	if eor == nil {
-		return nil, saved, fmt.Errorf("%d: %s: missing record terminator", sc.Line, sname)
+		err := &scanner.Error{Line: sc.Line, Col: sc.Col, Record: sname, Reason: "missing record terminator"}
+		if rpt, _ := sc.Repeat(saved); rpt != nil {
+			// the field starts the next record, so keep this one
+			record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
+			return &record, sc, err
+		}
+		return nil, sc, err
 	}
 	sc = bb
+	record.Span = scanner.Span{Start: saved.Pos(), End: sc.Pos()}
 
 	return &record, sc, nil
 }
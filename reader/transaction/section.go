@@ -27,25 +27,42 @@
 package transaction
 
 import (
+	"errors"
 	"fmt"
 	"github.com/maloquacious/qif/scanner"
 )
 
+// ErrUnsupportedAccountType is returned for account types that don't have
+// a transaction section.
+var ErrUnsupportedAccountType = errors.New("unsupported account type")
+
 type Section struct {
-	Line    int       `json:"-"`
-	Col     int       `json:"-"`
-	Records []*Record `json:"records,omitempty"`
+	Line        int       `json:"-"`
+	Col         int       `json:"-"`
+	Records     []*Record `json:"records,omitempty"`
+	Account     string    `json:"-"` // the account that the records belong to
+	AccountType string    `json:"-"`
+}
+
+// Read reads a record and adds it to the section. It returns false if
+// it doesn't find a record.
+func (s *Section) Read(sc scanner.Scanner) (bool, scanner.Scanner, error) {
+	record, sc, err := ReadRecord(sc, s.Account, s.AccountType)
+	if record != nil {
+		s.Records = append(s.Records, record)
+	}
+	return record != nil, sc, err
 }
 
 func ReadSection(sc scanner.Scanner, account, accountType string) (*Section, scanner.Scanner, error) {
-	saved, sname, section := sc, "transactions", Section{Line: sc.Line, Col: sc.Col}
+	saved, sname, section := sc, "transactions", Section{Line: sc.Line, Col: sc.Col, Account: account, AccountType: accountType}
 
 	var literal string
 	switch accountType {
 	case "Bank", "Cash", "CCard", "Invst", "Oth A", "Oth L", "Memorized", "Prices":
 		literal = "!Type:" + accountType
 	default:
-		panic(fmt.Sprintf("assert(account.type != %q)", accountType))
+		return nil, saved, fmt.Errorf("%d: %s: %w %q", section.Line, sname, ErrUnsupportedAccountType, accountType)
 	}
 	lit, bb := sc.Literal(literal)
 	if lit == nil {
@@ -54,22 +71,19 @@ func ReadSection(sc scanner.Scanner, account, accountType string) (*Section, sca
 	sc = bb
 
 	// read the section detail
-	var err error
 	for {
-		var record *Record
-		record, sc, err = ReadRecord(sc, account, accountType)
-		if err != nil {
+		var found bool
+		var err error
+		if found, sc, err = section.Read(sc); err != nil {
 			return nil, sc, fmt.Errorf("%d: %s: %w", section.Line, sname, err)
-		} else if record == nil {
+		} else if !found {
 			break
 		}
-		section.Records = append(section.Records, record)
 	}
 
 	// read the end of section marker
 	eos, bb := sc.EndOfSection()
 	if eos == nil {
-		fmt.Printf("error here %q\n", string(sc.Buffer[:20]))
 		return nil, saved, fmt.Errorf("%d: %s: %d:%d: unexpected input", section.Line, sname, sc.Line, sc.Col)
 	}
 	sc = bb
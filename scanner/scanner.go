@@ -27,117 +27,216 @@ package scanner
 import (
 	"bytes"
 	"fmt"
-	"unicode"
+	"github.com/maloquacious/qif/stdlib"
+	"strings"
 	"unicode/utf8"
 )
 
-// Scanner
+// Scanner reads QIF data from a buffer that has been translated to UTF-8.
+// The methods return the text that they accept (the lexeme) along with a
+// copy of the scanner that has moved past it. Lexemes are slices of the
+// buffer, not copies, so callers must copy them before changing them.
+//
+// Line and Col are kept up to date as the scanner moves rather than
+// looked up in an index of line offsets. Fields end at the end of their
+// line, so moving past one adds one to Line and resets Col without
+// counting anything; only the few bytes of a field code are counted.
 type Scanner struct {
-	Line   int
-	Col    int
+	Line   int // line number, starting at 1
+	Col    int // column in runes, starting at 1
+	Offset int // byte offset from the start of the buffer returned by New
 	Buffer []byte
+	Dates  stdlib.DateOptions // the format that Date accepts
+	// Passthrough tells record parsers to keep fields that they don't
+	// know (see Unknown) instead of stopping at them.
+	Passthrough bool
+	// Encoding is the encoding that the input was translated from.
+	Encoding stdlib.Encoding
 }
 
-// New returns a new scanner with a copy of the input.
-func New(input []byte) (Scanner, error) {
-	b, offset, line, col := make([]byte, 0, len(input)+1), 0, 1, 0
-	for offset < len(input) {
-		r, w := utf8.DecodeRune(input[offset:])
-		if r == utf8.RuneError {
-			return Scanner{}, fmt.Errorf("utf8: import: invalid utf-8 character on line %d, col %d", line, col)
-		} else if r == '\r' {
-			offset += w
-			continue
-		} else if r == '\n' {
-			line, col = line+1, 0
-		}
-		b, offset, col = append(b, input[offset:offset+w]...), offset+w, col+1
-	}
-	if len(b) == 0 || b[len(b)-1] != '\n' {
-		b = append(b, '\n')
-	}
-	return Scanner{Buffer: b, Line: 1}, nil
+// Position is a location in the input.
+type Position struct {
+	Offset int `json:"offset"` // byte offset, starting at 0
+	Line   int `json:"line"`   // line number, starting at 1
+	Col    int `json:"col"`    // column in runes, starting at 1
 }
 
-// Date will accept a date string which looks like
-//    digit digit? slash (space digit) digit tic digit digit
-func (buf Scanner) Date(flag string) ([]byte, Scanner) {
-	saved := buf
+// Span is the part of the input from Start up to, but not including, End.
+// Offsets are into the Buffer of the scanner returned by New, which has
+// been translated to UTF-8 and has no carriage returns.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
 
-	if !bytes.HasPrefix(buf.Buffer, []byte(flag)) {
-		return nil, buf
+// Text returns the text that the span covers.
+func (s Span) Text(source []byte) []byte {
+	if s.Start.Offset < 0 || s.End.Offset > len(source) || s.Start.Offset > s.End.Offset {
+		return nil
 	}
-	// skip the flag (we don't return it as part of the lexeme)
-	buf.Buffer, buf.Col = buf.Buffer[len(flag):], buf.Col+len(flag)
+	return source[s.Start.Offset:s.End.Offset]
+}
 
-	var length, w int
-	var r rune
+// New returns a new scanner with a copy of the input.
+// The encoding of the input is detected and translated to UTF-8.
+func New(input []byte) (Scanner, error) {
+	return NewWithEncoding(input, stdlib.EncodingAuto)
+}
 
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); !unicode.IsDigit(r) { // digit
-		return nil, saved
+// NewWithEncoding returns a new scanner with a copy of the input after
+// translating it from the given encoding to UTF-8.
+func NewWithEncoding(input []byte, encoding stdlib.Encoding) (Scanner, error) {
+	if encoding == stdlib.EncodingAuto {
+		encoding = stdlib.DetectEncoding(input)
 	}
-	length += w
-
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); unicode.IsDigit(r) { // digit?
-		length += w
+	if encoding == stdlib.EncodingUTF8 {
+		// checked below so that errors have the position of the problem
+		input = bytes.TrimPrefix(input, []byte{0xEF, 0xBB, 0xBF})
+	} else {
+		var err error
+		if input, _, err = stdlib.Transcode(input, encoding); err != nil {
+			return Scanner{}, err
+		}
 	}
 
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); r != '/' { // slash
-		return nil, saved
+	if !utf8.Valid(input) {
+		return Scanner{}, invalid(input)
 	}
-	length += w
 
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); !(r == ' ' || unicode.IsDigit(r)) { // (space digit)
-		return nil, saved
+	// copy the input, dropping carriage returns
+	b := make([]byte, 0, len(input)+1)
+	for rest := input; len(rest) != 0; {
+		cr := bytes.IndexByte(rest, '\r')
+		if cr == -1 {
+			b = append(b, rest...)
+			break
+		}
+		b, rest = append(b, rest[:cr]...), rest[cr+1:]
 	}
-	length += w
-
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); !unicode.IsDigit(r) { // digit
-		return nil, saved
+	if len(b) == 0 || b[len(b)-1] != '\n' {
+		b = append(b, '\n')
 	}
-	length += w
+	return Scanner{Buffer: b, Line: 1, Col: 1, Encoding: encoding}, nil
+}
 
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); r != '\'' { // tic
-		return nil, saved
+// invalid returns an error with the position of the first invalid
+// character in the input.
+func invalid(input []byte) error {
+	offset, line, col := 0, 1, 1
+	for offset < len(input) {
+		r, w := utf8.DecodeRune(input[offset:])
+		if r == utf8.RuneError && w == 1 {
+			break
+		} else if r == '\n' {
+			line, col = line+1, 0
+		}
+		if r != '\r' {
+			col++
+		}
+		offset += w
 	}
-	length += w
+	return fmt.Errorf("utf8: import: invalid utf-8 character on line %d, col %d", line, col)
+}
 
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); !unicode.IsDigit(r) { // digit
-		return nil, saved
+// Pos returns the position of the next rune in the buffer.
+func (buf Scanner) Pos() Position {
+	return Position{Offset: buf.Offset, Line: buf.Line, Col: buf.Col}
+}
+
+// advance consumes n bytes from the buffer and updates the position.
+func (buf Scanner) advance(n int) Scanner {
+	for _, c := range buf.Buffer[:n] {
+		if c == '\n' {
+			buf.Line, buf.Col = buf.Line+1, 1
+		} else if utf8.RuneStart(c) {
+			buf.Col++
+		}
 	}
-	length += w
+	buf.Buffer, buf.Offset = buf.Buffer[n:], buf.Offset+n
+	return buf
+}
 
-	if r, w = utf8.DecodeRune(buf.Buffer[length:]); !unicode.IsDigit(r) { // digit
-		return nil, saved
+// hasPrefix is bytes.HasPrefix for a string prefix. It doesn't allocate.
+func hasPrefix(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == prefix
+}
+
+// Date will accept a date only if the flag matches. The rest of the line
+// must be a valid date in the scanner's date format. It returns nil if
+// the date isn't accepted.
+func (buf Scanner) Date(flag string) (*stdlib.CivilDate, Scanner) {
+	saved := buf
+
+	if !hasPrefix(buf.Buffer, flag) {
+		return nil, buf
 	}
-	length += w
+	// skip the flag (we don't return it as part of the lexeme)
+	buf = buf.advance(len(flag))
 
-	lexeme := bdate(buf.Buffer[:length])
-	if lexeme == "****/**/**" {
+	// read the lexeme and consume to the end of the line
+	var lexeme []byte
+	lexeme, buf = buf.ToEndOfLine()
+
+	date, err := buf.Dates.Parse(string(lexeme))
+	if err != nil {
 		return nil, saved
 	}
 
-	// consume to the end of the line
-	_, buf = buf.ToEndOfLine()
-
-	// return the lexeme and updated buffer
-	return []byte(lexeme), buf
+	// return the date and updated buffer
+	return &date, buf
 }
 
 // Field will accept text to the end of the line only if the flag matches.
 func (buf Scanner) Field(flag string) ([]byte, Scanner) {
-	if !bytes.HasPrefix(buf.Buffer, []byte(flag)) {
+	if !hasPrefix(buf.Buffer, flag) {
 		return nil, buf
 	}
 	// skip the flag (we don't return it as part of the lexeme)
-	buf.Buffer, buf.Col = buf.Buffer[len(flag):], buf.Col+len(flag)
+	buf = buf.advance(len(flag))
+
+	// read the lexeme and consume to the end of the line
+	return buf.ToEndOfLine()
+}
+
+// Unknown will accept any field. The code is the first character of the
+// line and the lexeme is the rest of the line. It doesn't accept blank
+// lines, record terminators or section headers.
+func (buf Scanner) Unknown() (string, []byte, Scanner) {
+	if len(buf.Buffer) == 0 {
+		return "", nil, buf
+	}
+	switch buf.Buffer[0] {
+	case '\r', '\n', '^', '!':
+		return "", nil, buf
+	}
+	_, w := utf8.DecodeRune(buf.Buffer)
+	code := string(buf.Buffer[:w])
+	buf = buf.advance(w)
 
 	// read the lexeme and consume to the end of the line
 	var lexeme []byte
 	lexeme, buf = buf.ToEndOfLine()
 
-	// return the lexeme and updated buffer
-	return lexeme, buf
+	// return the code, lexeme and updated buffer
+	return code, lexeme, buf
+}
+
+// Repeat will accept a field only if its code also starts one of the
+// lines read since the start of the record. A record that stops at a
+// repeated field is missing its terminator, since the field must start
+// the next record. It does not actually consume the field.
+func (buf Scanner) Repeat(start Scanner) ([]byte, Scanner) {
+	code, _, _ := buf.Unknown()
+	if code == "" || start.Offset > buf.Offset {
+		return nil, buf
+	}
+	for _, line := range bytes.Split(start.Buffer[:buf.Offset-start.Offset], []byte{'\n'}) {
+		if hasPrefix(line, code) {
+			// return the lexeme and original buffer
+			return buf.Buffer[:len(code):len(code)], buf
+		}
+	}
+	return nil, buf
 }
 
 // EndOfLine will accept \r\n and \n.
@@ -146,29 +245,31 @@ func (buf Scanner) EndOfLine() ([]byte, Scanner) {
 		return nil, buf
 	}
 	if buf.Buffer[0] == '\n' {
-		lexeme := bdup([]byte{'\n'})
-		buf.Buffer, buf.Line, buf.Col = buf.Buffer[1:], buf.Line+1, 1
 		// return the lexeme and updated buffer
-		return lexeme, buf
+		return buf.Buffer[:1:1], buf.advance(1)
 	}
 	if len(buf.Buffer) > 1 && buf.Buffer[0] == '\r' && buf.Buffer[1] == '\n' {
-		lexeme := bdup([]byte{'\n'})
-		buf.Buffer, buf.Line, buf.Col = buf.Buffer[2:], buf.Line+1, 1
 		// return the lexeme and updated buffer
-		return lexeme, buf
+		return buf.Buffer[1:2:2], buf.advance(2)
 	}
 	return nil, buf
 }
 
+// markers are returned as the lexeme at the end of the input
+var (
+	endOfRecord  = []byte{'^'}
+	endOfSection = []byte{'!'}
+)
+
 // EndOfRecord will accept '^' or end-of-input.
 func (buf Scanner) EndOfRecord() ([]byte, Scanner) {
 	if len(buf.Buffer) == 0 {
-		return bdup([]byte{'^'}), buf
+		return endOfRecord, buf
 	} else if buf.Buffer[0] != '^' {
 		return nil, buf
 	}
 
-	lexeme := bdup(buf.Buffer[:1])
+	lexeme := buf.Buffer[:1:1]
 
 	// consume to the end of the line
 	_, buf = buf.ToEndOfLine()
@@ -181,26 +282,28 @@ func (buf Scanner) EndOfRecord() ([]byte, Scanner) {
 // It does not actually consume the marker.
 func (buf Scanner) EndOfSection() ([]byte, Scanner) {
 	if len(buf.Buffer) == 0 {
-		return bdup([]byte{'!'}), buf
+		return endOfSection, buf
 	} else if buf.Buffer[0] != '!' {
 		return nil, buf
 	}
-	lexeme := bdup(buf.Buffer[:1])
 	// return the lexeme and original buffer
-	return lexeme, buf
+	return buf.Buffer[:1:1], buf
 }
 
-// Literal will accept a literal.
-// The buffer's line and col variables will be hosed if the literal has an embedded newline.
+// Literal will accept a literal and consume the rest of the line that
+// the literal ends on. The literal may span lines.
 func (buf Scanner) Literal(lit string) ([]byte, Scanner) {
-	if !bytes.HasPrefix(buf.Buffer, []byte(lit)) {
+	if !hasPrefix(buf.Buffer, lit) {
 		return nil, buf
 	}
 
-	lexeme := bdup(buf.Buffer[len(lit):])
+	lexeme := buf.Buffer[:len(lit):len(lit)]
 
-	// consume to the end of the line
-	_, buf = buf.ToEndOfLine()
+	// consume the literal, then to the end of the line
+	buf = buf.advance(len(lit))
+	if !strings.HasSuffix(lit, "\n") {
+		_, buf = buf.ToEndOfLine()
+	}
 
 	// return the lexeme and updated buffer
 	return lexeme, buf
@@ -208,64 +311,18 @@ func (buf Scanner) Literal(lit string) ([]byte, Scanner) {
 
 // ToEndOfLine will consume all the text up to (and including) the next end of line.
 func (buf Scanner) ToEndOfLine() ([]byte, Scanner) {
-	// consume to the end of the line
-	var length int
-	r, w := utf8.DecodeRune(buf.Buffer[length:])
-	for r != utf8.RuneError && r != '\n' {
-		length, buf.Col = length+w, buf.Col+1
-		r, w = utf8.DecodeRune(buf.Buffer[length:])
+	length := bytes.IndexByte(buf.Buffer, '\n')
+	if length == -1 {
+		// the rest of the buffer is the last line
+		lexeme := buf.Buffer
+		buf.Buffer, buf.Offset, buf.Col = buf.Buffer[len(lexeme):], buf.Offset+len(lexeme), buf.Col+utf8.RuneCount(lexeme)
+		return lexeme, buf
 	}
 
-	lexeme := bdup(buf.Buffer[:length])
-
-	if r == '\n' {
-		buf.Line, buf.Col = buf.Line+1, 1
-		length++
-	}
-	buf.Buffer = buf.Buffer[length:]
+	// consume the line, including the new-line
+	lexeme := buf.Buffer[:length:length]
+	buf.Buffer, buf.Offset, buf.Line, buf.Col = buf.Buffer[length+1:], buf.Offset+length+1, buf.Line+1, 1
 
 	// return the lexeme and updated buffer
 	return lexeme, buf
 }
-
-// bdate translates QIF date to a string with the date formatted as yyyy/mm/dd
-// The QIF date is formatted as mm/dd'yy. The month can be one or two digits
-// (eg, January is `1` while October is `10`). The day must be two characters,
-// but the first character may be a space instead of a zero. For example,
-// `01` and ` 1` are both the first day of the month. The year must be two
-// digits, and we're assuming it is always in the 21st century (eg, `16` is
-// converted to 2016, not 1916).
-func bdate(b []byte) string {
-	// 9/ 3'16 -> 2016/09/03
-	// 9/13'16 -> 2016/09/13
-	if len(b) == 7 && b[1] == '/' && b[4] == '\'' {
-		mm, dd, yy := b[0:1], b[2:4], b[5:]
-		return fmt.Sprintf("%4d/%02d/%02d", bint(yy)+2000, bint(mm), bint(dd))
-	}
-
-	// 12/ 9'16 -> 2016/12/09
-	// 12/19'16 -> 2016/12/19
-	if len(b) == 8 && b[2] == '/' && b[5] == '\'' {
-		mm, dd, yy := b[0:2], b[3:6], b[6:]
-		return fmt.Sprintf("%4d/%02d/%02d", bint(yy)+2000, bint(mm), bint(dd))
-	}
-
-	// invalid date
-	return "****/**/**"
-}
-
-func bdup(src []byte) []byte {
-	dst := make([]byte, len(src))
-	copy(dst, src)
-	return dst
-}
-
-// bint converts a slice to an int.
-func bint(b []byte) (i int) {
-	for pos := 0; pos < len(b); pos++ {
-		if '0' <= b[pos] && b[pos] <= '9' {
-			i = i*10 + int(b[pos]) - '0'
-		}
-	}
-	return i
-}
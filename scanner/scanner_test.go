@@ -0,0 +1,147 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package scanner_test
+
+import (
+	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	// Specification: Scanner positions
+
+	// Given a payee with multibyte characters
+	sc, err := scanner.New([]byte("PCafé Olé\nT-1.00\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the field is read
+	payee, sc := sc.Field("P")
+
+	// Then the scanner is at the start of the next line
+	if string(payee) != "Café Olé" {
+		t.Errorf("payee: yields %q: expected %q\n", payee, "Café Olé")
+	}
+	if expect := (scanner.Position{Offset: 12, Line: 2, Col: 1}); sc.Pos() != expect {
+		t.Errorf("field: yields %+v: expected %+v\n", sc.Pos(), expect)
+	}
+
+	// Given a final line without a new-line
+	sc = scanner.Scanner{Buffer: []byte("PCafé"), Line: 3, Col: 1, Offset: 20}
+
+	// When it is read to the end of the line
+	line, sc := sc.ToEndOfLine()
+
+	// Then the column counts runes and the offset counts bytes
+	if string(line) != "PCafé" || len(sc.Buffer) != 0 {
+		t.Errorf("line: yields %q %q: expected %q %q\n", line, sc.Buffer, "PCafé", "")
+	}
+	if expect := (scanner.Position{Offset: 26, Line: 3, Col: 6}); sc.Pos() != expect {
+		t.Errorf("last line: yields %+v: expected %+v\n", sc.Pos(), expect)
+	}
+
+	// Given UTF-8 input with an invalid character after a multibyte character
+	// When a scanner is created
+	// Then the error has the rune column of the character
+	if _, err := scanner.NewWithEncoding([]byte("PCafé\xff\n"), stdlib.EncodingUTF8); err == nil || err.Error() != "utf8: import: invalid utf-8 character on line 1, col 6" {
+		t.Errorf("invalid: yields %v: expected %q\n", err, "utf8: import: invalid utf-8 character on line 1, col 6")
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	// Specification: Literals that span lines
+
+	input := "!Option:AutoSwitch\n!Account\nNCafé\n"
+	for _, tc := range []struct {
+		literal string
+		expect  scanner.Position
+	}{
+		// Given a literal that ends with a new-line
+		// Then the scanner is at the start of the next line
+		{"!Option:AutoSwitch\n", scanner.Position{Offset: 19, Line: 2, Col: 1}},
+		// Given a literal that ends part way through a line
+		// Then the scanner skips the rest of that line
+		{"!Option:AutoSwitch\n!Acc", scanner.Position{Offset: 28, Line: 3, Col: 1}},
+	} {
+		sc, err := scanner.New([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// When the literal is read
+		lit, bb := sc.Literal(tc.literal)
+		if string(lit) != tc.literal {
+			t.Errorf("literal %q: yields %q\n", tc.literal, lit)
+		}
+		if bb.Pos() != tc.expect {
+			t.Errorf("literal %q: yields %+v: expected %+v\n", tc.literal, bb.Pos(), tc.expect)
+		}
+	}
+
+	// Given a literal that doesn't match
+	// When it is read
+	// Then the scanner doesn't move
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lit, bb := sc.Literal("!Account"); lit != nil || bb.Pos() != sc.Pos() {
+		t.Errorf("literal %q: yields %q %+v: expected nil %+v\n", "!Account", lit, bb.Pos(), sc.Pos())
+	}
+}
+
+func TestSpanText(t *testing.T) {
+	// Specification: Span text
+
+	// Given the span of a field with multibyte characters
+	sc, err := scanner.New([]byte("D1/ 4'16\nPCafé Olé\nT-1.00\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, start := sc.ToEndOfLine()
+	_, end := start.Field("P")
+	span := scanner.Span{Start: start.Pos(), End: end.Pos()}
+
+	// When its text is taken from the buffer
+	// Then it is the whole line
+	if text := span.Text(sc.Buffer); string(text) != "PCafé Olé\n" {
+		t.Errorf("text: yields %q: expected %q\n", text, "PCafé Olé\n")
+	}
+
+	// Given spans that are out of range or backwards
+	// When their text is taken
+	// Then it is nil
+	for _, span := range []scanner.Span{
+		{Start: scanner.Position{Offset: -1}, End: end.Pos()},
+		{Start: start.Pos(), End: scanner.Position{Offset: len(sc.Buffer) + 1}},
+		{Start: end.Pos(), End: start.Pos()},
+	} {
+		if text := span.Text(sc.Buffer); text != nil {
+			t.Errorf("span %+v: yields %q: expected nil\n", span, text)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// AmountPlaces is the number of digits that an Amount keeps after the
+// decimal point. Six is enough for the share prices and quantities that
+// Quicken exports.
+const AmountPlaces = 6
+
+// amountScale is 10 ** AmountPlaces.
+const amountScale = 1000000
+
+// maxMicros is the largest value that an Amount can hold. The range is
+// kept symmetric so that Neg and Abs never overflow.
+const maxMicros = 1<<63 - 1
+
+// ErrDivideByZero is returned when an Amount is divided by zero.
+var ErrDivideByZero = errors.New("divide by zero")
+
+// ErrOutOfRange is returned when the result of a division does not fit
+// in an Amount.
+var ErrOutOfRange = errors.New("out of range")
+
+// Amount is an exact decimal number. It is stored as an integer count of
+// millionths so that adding and comparing amounts never suffers from
+// floating point rounding. The range is a little over +/- nine trillion,
+// which is plenty for household finances. Multiplication saturates at
+// the ends of the range rather than wrapping around.
+//
+// The zero value is zero.
+type Amount struct {
+	micros int64
+}
+
+// NewAmount returns an Amount for a whole number.
+func NewAmount(i int64) Amount {
+	return Amount{micros: i * amountScale}
+}
+
+// ParseAmount translates a string to an Amount.
+//
+// The amount may have a leading sign or be wrapped in parentheses to
+// show that it is negative. Both US (`1,234.56`) and European (`1.234,56`)
+// styles are accepted. When both `.` and `,` are present, the last one is
+// the decimal separator. When only `,` is present, it is a thousands
+// separator if every group after it has three digits (`1,234`), otherwise
+// it is the decimal separator (`1234,56`). When only `.` is present, it is
+// the decimal separator unless there is more than one (`1.234.567`).
+// Spaces and apostrophes may also be used as thousands separators.
+//
+// Digits past the sixth decimal place are rounded half away from zero.
+func ParseAmount(s string) (Amount, error) {
+	input := s
+	s = strings.TrimSpace(s)
+
+	var negative bool
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, strings.TrimSpace(s[1:len(s)-1])
+	}
+	if strings.HasPrefix(s, "-") {
+		negative, s = !negative, s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if s == "" {
+		return Amount{}, fmt.Errorf("amount: invalid amount %q", input)
+	}
+
+	// find the decimal separator, if there is one
+	decimal, thousands := -1, ""
+	dot, comma := strings.LastIndexByte(s, '.'), strings.LastIndexByte(s, ',')
+	switch {
+	case dot != -1 && comma != -1 && dot > comma:
+		decimal, thousands = dot, ","
+	case dot != -1 && comma != -1:
+		decimal, thousands = comma, "."
+	case comma != -1:
+		if strings.Count(s, ",") == 1 && len(s)-comma-1 != 3 {
+			decimal = comma
+		} else {
+			thousands = ","
+		}
+	case dot != -1:
+		if strings.Count(s, ".") == 1 {
+			decimal = dot
+		} else {
+			thousands = "."
+		}
+	}
+
+	whole, fraction := s, ""
+	if decimal != -1 {
+		whole, fraction = s[:decimal], s[decimal+1:]
+	}
+
+	// validate the thousands groups and strip the separators
+	var groups []string
+	for start, i := 0, 0; i <= len(whole); i++ {
+		if i == len(whole) || strings.IndexByte(thousands, whole[i]) != -1 || whole[i] == ' ' || whole[i] == '\'' {
+			groups, start = append(groups, whole[start:i]), i+1
+		}
+	}
+	for i, group := range groups {
+		if len(groups) > 1 && (group == "" || (i != 0 && len(group) != 3)) {
+			return Amount{}, fmt.Errorf("amount: invalid amount %q", input)
+		}
+	}
+	whole = strings.Join(groups, "")
+	if whole == "" && fraction == "" {
+		return Amount{}, fmt.Errorf("amount: invalid amount %q", input)
+	}
+
+	var micros int64
+	for _, ch := range whole {
+		if !('0' <= ch && ch <= '9') {
+			return Amount{}, fmt.Errorf("amount: invalid amount %q", input)
+		}
+		digit := int64(ch - '0')
+		if micros > (maxMicros/amountScale-digit)/10 {
+			return Amount{}, fmt.Errorf("amount: %q is out of range", input)
+		}
+		micros = micros*10 + digit
+	}
+	micros *= amountScale
+
+	scale := int64(amountScale)
+	for i, ch := range fraction {
+		if !('0' <= ch && ch <= '9') {
+			return Amount{}, fmt.Errorf("amount: invalid amount %q", input)
+		}
+		if i < AmountPlaces {
+			scale /= 10
+			digit := int64(ch-'0') * scale
+			if micros > maxMicros-digit {
+				return Amount{}, fmt.Errorf("amount: %q is out of range", input)
+			}
+			micros += digit
+		} else if i == AmountPlaces && ch >= '5' {
+			if micros == maxMicros {
+				return Amount{}, fmt.Errorf("amount: %q is out of range", input)
+			}
+			micros++
+		}
+	}
+
+	if negative {
+		micros = -micros
+	}
+	return Amount{micros: micros}, nil
+}
+
+// Abs returns the absolute value of a.
+func (a Amount) Abs() Amount {
+	if a.micros < 0 {
+		return Amount{micros: -a.micros}
+	}
+	return a
+}
+
+// Add returns a + b.
+func (a Amount) Add(b Amount) Amount {
+	return Amount{micros: a.micros + b.micros}
+}
+
+// Cmp returns -1 if a < b, 0 if a == b, and +1 if a > b.
+func (a Amount) Cmp(b Amount) int {
+	if a.micros < b.micros {
+		return -1
+	} else if a.micros > b.micros {
+		return 1
+	}
+	return 0
+}
+
+// Div returns a / b rounded half away from zero.
+func (a Amount) Div(b Amount) (Amount, error) {
+	if b.micros == 0 {
+		return Amount{}, ErrDivideByZero
+	}
+	n := new(big.Int).Mul(big.NewInt(a.micros), big.NewInt(amountScale))
+	micros, ok := quo(n, big.NewInt(b.micros))
+	if !ok {
+		return Amount{}, ErrOutOfRange
+	}
+	return Amount{micros: micros}, nil
+}
+
+// IsZero returns true if a is zero.
+func (a Amount) IsZero() bool {
+	return a.micros == 0
+}
+
+// Mul returns a * b rounded half away from zero. A product that does not
+// fit in an Amount is clamped to the largest or smallest Amount.
+func (a Amount) Mul(b Amount) Amount {
+	n := new(big.Int).Mul(big.NewInt(a.micros), big.NewInt(b.micros))
+	micros, _ := quo(n, big.NewInt(amountScale))
+	return Amount{micros: micros}
+}
+
+// Neg returns -a.
+func (a Amount) Neg() Amount {
+	return Amount{micros: -a.micros}
+}
+
+// Round returns a rounded half away from zero to the given number of
+// decimal places.
+func (a Amount) Round(places int) Amount {
+	if places >= AmountPlaces {
+		return a
+	} else if places < 0 {
+		places = 0
+	}
+	factor := int64(1)
+	for i := places; i < AmountPlaces; i++ {
+		factor *= 10
+	}
+	q, _ := quo(big.NewInt(a.micros), big.NewInt(factor))
+	// rounding away from zero can step past the ends of the range
+	if q > maxMicros/factor {
+		q--
+	} else if q < -maxMicros/factor {
+		q++
+	}
+	return Amount{micros: q * factor}
+}
+
+// Sign returns -1 if a < 0, 0 if a == 0, and +1 if a > 0.
+func (a Amount) Sign() int {
+	return a.Cmp(Amount{})
+}
+
+// String returns the amount with at least two decimal places and no
+// thousands separators (eg, `-1234.50` or `107.7312`).
+func (a Amount) String() string {
+	s := a.StringFixed(AmountPlaces)
+	for strings.HasSuffix(s, "0") && len(s)-strings.IndexByte(s, '.') > 3 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// StringFixed returns the amount rounded to the given number of decimal
+// places with no thousands separators.
+func (a Amount) StringFixed(places int) string {
+	if places < 0 {
+		places = 0
+	} else if places > AmountPlaces {
+		places = AmountPlaces
+	}
+	micros, sign := a.Round(places).micros, ""
+	if micros < 0 {
+		micros, sign = -micros, "-"
+	}
+	whole, fraction := micros/amountScale, fmt.Sprintf("%06d", micros%amountScale)
+	if places == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	return fmt.Sprintf("%s%d.%s", sign, whole, fraction[:places])
+}
+
+// Sub returns a - b.
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{micros: a.micros - b.micros}
+}
+
+// WithinCent returns true if a and b differ by no more than one cent.
+// Quicken rounds the amounts that it exports, so amounts that are
+// computed from them may be off by a cent.
+func (a Amount) WithinCent(b Amount) bool {
+	return a.Sub(b).Abs().micros <= amountScale/100
+}
+
+// quo returns n / d rounded half away from zero. If the quotient does
+// not fit in an int64, it returns the nearest int64 and false.
+func quo(n, d *big.Int) (int64, bool) {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	// compare twice the remainder to the divisor to decide on rounding
+	r.Abs(r).Lsh(r, 1)
+	if r.Cmp(new(big.Int).Abs(d)) >= 0 {
+		if n.Sign()*d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		if q.Sign() < 0 {
+			return -maxMicros, false
+		}
+		return maxMicros, false
+	}
+	return q.Int64(), true
+}
@@ -0,0 +1,165 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package stdlib_test
+
+import (
+	"github.com/maloquacious/qif/stdlib"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	// Specification: ParseAmount
+
+	// When a valid amount is parsed
+	// Then it has the expected value
+	for _, tc := range []struct {
+		input, expected string
+	}{
+		{"0.00", "0.00"},
+		{"12.45", "12.45"},
+		{"-12.45", "-12.45"},
+		{"+12.45", "12.45"},
+		{"(12.45)", "-12.45"},
+		{"1,234.56", "1234.56"},
+		{"1,234", "1234.00"},
+		{"1,234,567.8", "1234567.80"},
+		{"1.234,56", "1234.56"},
+		{"1.234.567", "1234567.00"},
+		{"1234,56", "1234.56"},
+		{"1 234,56", "1234.56"},
+		{".5", "0.50"},
+		{"107.7312", "107.7312"},
+		{"0.12345649", "0.123456"},
+		{"0.1234565", "0.123457"},
+		{"-0.0000005", "-0.000001"},
+		{"9223372036854", "9223372036854.00"},
+		{"9223372036854.775807", "9223372036854.775807"},
+		{"-9223372036854.775807", "-9223372036854.775807"},
+		{"9223372036854.7758074", "9223372036854.775807"},
+	} {
+		yields, err := stdlib.ParseAmount(tc.input)
+		if err != nil {
+			t.Errorf("input of %q yields error %v: expected value is %q\n", tc.input, err, tc.expected)
+		} else if yields.String() != tc.expected {
+			t.Errorf("input of %q yields %q: expected value is %q\n", tc.input, yields.String(), tc.expected)
+		}
+	}
+
+	// When an invalid amount is parsed
+	// Then it returns an error
+	for _, input := range []string{"", "-", "()", "abc", "1,23,456", "1.234.56", ",123", "1,,234", "12.3.4,5", "1e5"} {
+		if yields, err := stdlib.ParseAmount(input); err == nil {
+			t.Errorf("input of %q yields %q: expected error\n", input, yields.String())
+		}
+	}
+
+	// When an amount is too large for an Amount
+	// Then it returns an error
+	for _, input := range []string{"9223372036855", "9223372036859", "92233720368540", "9223372036854.775808", "9223372036854.7758075", "-9223372036859"} {
+		if yields, err := stdlib.ParseAmount(input); err == nil {
+			t.Errorf("input of %q yields %q: expected error\n", input, yields.String())
+		}
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	// Specification: Amount arithmetic
+
+	parse := func(s string) stdlib.Amount {
+		a, err := stdlib.ParseAmount(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	// When 0.10 is added three times
+	// Then the sum is exactly 0.30
+	sum := parse("0.10").Add(parse("0.10")).Add(parse("0.10"))
+	if sum.Cmp(parse("0.30")) != 0 {
+		t.Errorf("0.10 + 0.10 + 0.10 yields %q: expected value is %q\n", sum, "0.30")
+	}
+
+	// When 10 shares are bought at 107.73
+	// Then the cost is 1077.30
+	if yields := parse("10").Mul(parse("107.73")); yields.String() != "1077.30" {
+		t.Errorf("10 * 107.73 yields %q: expected value is %q\n", yields, "1077.30")
+	}
+
+	// When 100.00 is divided by 3
+	// Then the result is rounded to 33.333333
+	if yields, err := parse("100.00").Div(parse("3")); err != nil || yields.String() != "33.333333" {
+		t.Errorf("100 / 3 yields %q, %v: expected value is %q\n", yields, err, "33.333333")
+	}
+
+	// When -2.675 is rounded to two places
+	// Then it is rounded away from zero
+	if yields := parse("-2.675").StringFixed(2); yields != "-2.68" {
+		t.Errorf("-2.675 yields %q: expected value is %q\n", yields, "-2.68")
+	}
+
+	// When 12.45 is negated
+	// Then it has the value -12.45
+	if yields := parse("12.45").Neg(); yields.String() != "-12.45" {
+		t.Errorf("-(12.45) yields %q: expected value is %q\n", yields, "-12.45")
+	}
+
+	// When amounts are compared to the cent
+	// Then a difference of one cent is tolerated and more is not
+	if !parse("10.00").WithinCent(parse("10.01")) || !parse("10.01").WithinCent(parse("10.00")) {
+		t.Errorf("10.00 ~ 10.01 yields false: expected value is true\n")
+	}
+	if parse("10.00").WithinCent(parse("10.011")) {
+		t.Errorf("10.00 ~ 10.011 yields true: expected value is false\n")
+	}
+
+	// When an amount is divided by zero
+	// Then it returns an error
+	if _, err := parse("1").Div(stdlib.Amount{}); err != stdlib.ErrDivideByZero {
+		t.Errorf("1 / 0 yields %v: expected ErrDivideByZero\n", err)
+	}
+
+	// When a product is too large for an Amount
+	// Then it is clamped to the ends of the range
+	largest, smallest := parse("9223372036854.775807"), parse("-9223372036854.775807")
+	if yields := parse("1000000").Mul(parse("1000000000")); yields.Cmp(largest) != 0 {
+		t.Errorf("1000000 * 1000000000 yields %q: expected value is %q\n", yields, largest)
+	}
+	if yields := parse("-1000000").Mul(parse("1000000000")); yields.Cmp(smallest) != 0 {
+		t.Errorf("-1000000 * 1000000000 yields %q: expected value is %q\n", yields, smallest)
+	}
+
+	// When a quotient is too large for an Amount
+	// Then it returns an error
+	if yields, err := largest.Div(parse("0.5")); err != stdlib.ErrOutOfRange {
+		t.Errorf("%s / 0.5 yields %q, %v: expected ErrOutOfRange\n", largest, yields, err)
+	}
+
+	// When the largest Amount is rounded to the cent
+	// Then it stays in range
+	if yields := largest.StringFixed(2); yields != "9223372036854.77" {
+		t.Errorf("%s yields %q: expected value is %q\n", largest, yields, "9223372036854.77")
+	}
+}
@@ -0,0 +1,160 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package stdlib
+
+import (
+	"fmt"
+	"time"
+)
+
+// CivilDate is a calendar date with no time of day or time zone.
+// Dates can be compared with == and ordered with Compare.
+//
+// The zero value means that there is no date.
+type CivilDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// CivilDateOf returns the date of t in t's location.
+func CivilDateOf(t time.Time) CivilDate {
+	year, month, day := t.Date()
+	return CivilDate{Year: year, Month: month, Day: day}
+}
+
+// ParseCivilDate parses an ISO 8601 date (eg, `2016-09-03`).
+func ParseCivilDate(s string) (CivilDate, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return CivilDate{}, fmt.Errorf("date: invalid date %q", s)
+	}
+	return CivilDateOf(t), nil
+}
+
+// AddDays returns the date n days after d.
+func (d CivilDate) AddDays(n int) CivilDate {
+	return CivilDateOf(d.Time().AddDate(0, 0, n))
+}
+
+// AddMonths returns the date n months after d. If the day doesn't exist
+// in the new month, it is the last day of the month instead (eg, one
+// month after January 31 is the end of February).
+func (d CivilDate) AddMonths(n int) CivilDate {
+	months := d.Year*12 + int(d.Month) - 1 + n
+	year, month := months/12, time.Month(months%12+1)
+	day := d.Day
+	if max := DaysIn(year, int(month)); day > max {
+		day = max
+	}
+	return CivilDate{Year: year, Month: month, Day: day}
+}
+
+// After returns true if d is after o.
+func (d CivilDate) After(o CivilDate) bool {
+	return d.Compare(o) > 0
+}
+
+// Before returns true if d is before o.
+func (d CivilDate) Before(o CivilDate) bool {
+	return d.Compare(o) < 0
+}
+
+// Compare returns -1 if d is before o, 0 if they are the same date,
+// and +1 if d is after o.
+func (d CivilDate) Compare(o CivilDate) int {
+	switch {
+	case d.Year != o.Year:
+		if d.Year < o.Year {
+			return -1
+		}
+		return 1
+	case d.Month != o.Month:
+		if d.Month < o.Month {
+			return -1
+		}
+		return 1
+	case d.Day != o.Day:
+		if d.Day < o.Day {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// DaysSince returns the number of days from o to d.
+func (d CivilDate) DaysSince(o CivilDate) int {
+	return int(d.Time().Sub(o.Time()).Hours() / 24)
+}
+
+// Format returns the date formatted with a time package layout
+// (eg, "2006/01/02"). The zero date is formatted as an empty string.
+func (d CivilDate) Format(layout string) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Time().Format(layout)
+}
+
+// IsValid returns true if the date exists on the calendar.
+func (d CivilDate) IsValid() bool {
+	return 1 <= d.Month && d.Month <= 12 && 1 <= d.Day && d.Day <= DaysIn(d.Year, int(d.Month))
+}
+
+// IsZero returns true if d is the zero date.
+func (d CivilDate) IsZero() bool {
+	return d == CivilDate{}
+}
+
+// MarshalText implements encoding.TextMarshaler using ISO 8601.
+func (d CivilDate) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// String returns the date in ISO 8601 format (eg, `2016-09-03`).
+func (d CivilDate) String() string {
+	return d.Format("2006-01-02")
+}
+
+// Time returns midnight UTC at the start of the date.
+func (d CivilDate) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ISO 8601.
+// An empty string is the zero date.
+func (d *CivilDate) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*d = CivilDate{}
+		return nil
+	}
+	date, err := ParseCivilDate(string(b))
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
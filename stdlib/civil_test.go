@@ -0,0 +1,90 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package stdlib_test
+
+import (
+	"encoding/json"
+	"github.com/maloquacious/qif/stdlib"
+	"testing"
+	"time"
+)
+
+func TestCivilDate(t *testing.T) {
+	// Specification: CivilDate
+
+	// When dates are compared
+	// Then they are ordered by year, month and day
+	a := stdlib.CivilDate{Year: 2016, Month: time.September, Day: 3}
+	b := stdlib.CivilDate{Year: 2016, Month: time.October, Day: 1}
+	if !a.Before(b) || b.Before(a) || !b.After(a) || a.Compare(a) != 0 {
+		t.Errorf("compare %s and %s: expected %s to be first\n", a, b, a)
+	}
+
+	// When a date is formatted
+	// Then it uses the layout
+	if yields := a.Format("01/02/2006"); yields != "09/03/2016" {
+		t.Errorf("format %s yields %q: expected value is %q\n", a, yields, "09/03/2016")
+	}
+
+	// When months are added past the end of a short month
+	// Then the date is the last day of that month
+	for _, tc := range []struct {
+		input    stdlib.CivilDate
+		months   int
+		expected string
+	}{
+		{stdlib.CivilDate{Year: 2016, Month: time.January, Day: 31}, 1, "2016-02-29"},
+		{stdlib.CivilDate{Year: 2017, Month: time.January, Day: 31}, 1, "2017-02-28"},
+		{stdlib.CivilDate{Year: 2016, Month: time.November, Day: 15}, 3, "2017-02-15"},
+		{stdlib.CivilDate{Year: 2016, Month: time.March, Day: 15}, -3, "2015-12-15"},
+	} {
+		if yields := tc.input.AddMonths(tc.months).String(); yields != tc.expected {
+			t.Errorf("%s plus %d months yields %q: expected value is %q\n", tc.input, tc.months, yields, tc.expected)
+		}
+	}
+
+	// When days are added
+	// Then DaysSince returns the number of days
+	if yields := a.AddDays(28); yields != b || b.DaysSince(a) != 28 {
+		t.Errorf("%s plus 28 days yields %s: expected value is %s\n", a, yields, b)
+	}
+
+	// When a date is marshaled to JSON
+	// Then it is an ISO 8601 string that unmarshals to the same date
+	data, err := json.Marshal(a)
+	if err != nil || string(data) != `"2016-09-03"` {
+		t.Errorf("marshal %s yields %s, %v: expected value is %q\n", a, data, err, `"2016-09-03"`)
+	}
+	var c stdlib.CivilDate
+	if err := json.Unmarshal(data, &c); err != nil || c != a {
+		t.Errorf("unmarshal %s yields %s, %v: expected value is %s\n", data, c, err, a)
+	}
+
+	// When the zero date is formatted
+	// Then it is an empty string
+	if yields := (stdlib.CivilDate{}).String(); yields != "" {
+		t.Errorf("zero date yields %q: expected empty string\n", yields)
+	}
+}
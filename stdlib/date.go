@@ -0,0 +1,234 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package stdlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat is the order of the fields in a QIF date.
+type DateFormat int
+
+const (
+	DateAuto     DateFormat = iota // detect the format from the data
+	DateUS                         // m/d/y
+	DateEuropean                   // d/m/y
+	DateISO                        // y/m/d, the year must have four digits
+	DateQuicken                    // m/d'yy for 20yy and m/d/yy for 19yy
+)
+
+// DefaultCenturyPivot is used when DateOptions.CenturyPivot is zero.
+const DefaultCenturyPivot = 70
+
+// DateOptions controls how dates are parsed.
+//
+// The fields in a date may be separated by `/`, `-`, `.`, or `'` and may
+// be padded with leading spaces (eg, `1/ 2/98`). A two-digit year that
+// follows an apostrophe is always in the 21st century. Otherwise, years
+// below the CenturyPivot are in the 21st century and the rest are in the
+// 20th. DateQuicken ignores the pivot; it follows Quicken's convention
+// that only the apostrophe marks the 21st century.
+type DateOptions struct {
+	Format       DateFormat
+	CenturyPivot int
+}
+
+// ParseDateFormat translates the name of a format to a DateFormat.
+func ParseDateFormat(name string) (DateFormat, error) {
+	for _, f := range []DateFormat{DateAuto, DateUS, DateEuropean, DateISO, DateQuicken} {
+		if strings.EqualFold(name, f.String()) {
+			return f, nil
+		}
+	}
+	return DateAuto, fmt.Errorf("date: unknown format %q", name)
+}
+
+func (f DateFormat) String() string {
+	switch f {
+	case DateAuto:
+		return "auto"
+	case DateUS:
+		return "us"
+	case DateEuropean:
+		return "european"
+	case DateISO:
+		return "iso"
+	case DateQuicken:
+		return "quicken"
+	}
+	return fmt.Sprintf("DateFormat(%d)", int(f))
+}
+
+// DetectDateFormat returns the format that parses the most samples.
+// Ties go to DateUS, then DateEuropean, since those are Quicken's
+// defaults. It returns DateUS if there are no samples.
+func DetectDateFormat(samples []string, opts DateOptions) DateFormat {
+	best, bestCount := DateUS, -1
+	for _, f := range []DateFormat{DateUS, DateEuropean, DateISO} {
+		o, count := DateOptions{Format: f, CenturyPivot: opts.CenturyPivot}, 0
+		for _, sample := range samples {
+			if _, ok := o.parse(sample); ok {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = f, count
+		}
+	}
+	return best
+}
+
+// Parse translates a date to a CivilDate.
+// With DateAuto, it uses the first of DateUS, DateEuropean and DateISO
+// that gives a valid date.
+func (o DateOptions) Parse(s string) (CivilDate, error) {
+	switch o.Format {
+	case DateAuto, DateUS, DateEuropean, DateISO, DateQuicken:
+	default:
+		return CivilDate{}, fmt.Errorf("date: unknown format %d", int(o.Format))
+	}
+	date, ok := o.parse(s)
+	if !ok {
+		return CivilDate{}, fmt.Errorf("date: invalid date %q", s)
+	}
+	return date, nil
+}
+
+// parse is Parse without the cost of building an error.
+func (o DateOptions) parse(s string) (CivilDate, bool) {
+	fields, tic, ok := dateFields(s)
+	if !ok {
+		return CivilDate{}, false
+	}
+
+	var y, m, d int
+	switch o.Format {
+	case DateAuto:
+		for _, f := range []DateFormat{DateUS, DateEuropean, DateISO} {
+			if date, ok := (DateOptions{Format: f, CenturyPivot: o.CenturyPivot}).parse(s); ok {
+				return date, true
+			}
+		}
+		return CivilDate{}, false
+	case DateUS, DateQuicken:
+		m, d, y = 0, 1, 2
+	case DateEuropean:
+		d, m, y = 0, 1, 2
+	case DateISO:
+		if tic || len(fields[0]) != 4 {
+			return CivilDate{}, false
+		}
+		y, m, d = 0, 1, 2
+	default:
+		return CivilDate{}, false
+	}
+
+	if len(fields[m]) > 2 || len(fields[d]) > 2 {
+		return CivilDate{}, false
+	}
+	yyyy, _ := strconv.Atoi(fields[y])
+	mm, _ := strconv.Atoi(fields[m])
+	dd, _ := strconv.Atoi(fields[d])
+
+	switch len(fields[y]) {
+	case 1, 2:
+		pivot := o.CenturyPivot
+		if pivot == 0 {
+			pivot = DefaultCenturyPivot
+		}
+		if tic || (o.Format != DateQuicken && yyyy < pivot) {
+			yyyy += 2000
+		} else {
+			yyyy += 1900
+		}
+	case 4:
+		if tic {
+			return CivilDate{}, false
+		}
+	default:
+		return CivilDate{}, false
+	}
+
+	if !(1 <= mm && mm <= 12) || !(1 <= dd && dd <= DaysIn(yyyy, mm)) {
+		return CivilDate{}, false
+	}
+
+	return CivilDate{Year: yyyy, Month: time.Month(mm), Day: dd}, true
+}
+
+// DaysIn returns the number of days in the month.
+func DaysIn(year, month int) int {
+	switch month {
+	case 1, 3, 5, 7, 8, 10, 12:
+		return 31
+	case 4, 6, 9, 11:
+		return 30
+	case 2:
+		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			return 29
+		}
+		return 28
+	}
+	return 0
+}
+
+// dateFields splits a date into three fields of digits. Leading spaces in
+// a field are ignored. It also reports if the year was separated by an
+// apostrophe.
+func dateFields(s string) (fields [3]string, tic, ok bool) {
+	s = strings.TrimSpace(s)
+	n, start := 0, -1
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			if start == -1 {
+				start = i
+			}
+			continue
+		} else if i < len(s) && s[i] == ' ' && start == -1 {
+			continue
+		}
+		if start == -1 || n == 3 {
+			return fields, false, false
+		}
+		fields[n], n, start = s[start:i], n+1, -1
+		if i == len(s) {
+			break
+		}
+		switch s[i] {
+		case '/', '-', '.':
+		case '\'':
+			if n != 2 {
+				return fields, false, false
+			}
+			tic = true
+		default:
+			return fields, false, false
+		}
+	}
+	return fields, tic, n == 3
+}
@@ -0,0 +1,113 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package stdlib_test
+
+import (
+	"github.com/maloquacious/qif/stdlib"
+	"testing"
+)
+
+func TestDateOptions(t *testing.T) {
+	// Specification: DateOptions
+
+	// When a date is parsed with a format
+	// Then it has the expected value
+	for _, tc := range []struct {
+		format          stdlib.DateFormat
+		pivot           int
+		input, expected string
+	}{
+		{stdlib.DateUS, 0, "9/ 3'16", "2016-09-03"},
+		{stdlib.DateUS, 0, "12/31/1999", "1999-12-31"},
+		{stdlib.DateUS, 0, "12/31/99", "1999-12-31"},
+		{stdlib.DateUS, 0, "1/ 2/98", "1998-01-02"},
+		{stdlib.DateUS, 0, "6/ 1' 4", "2004-06-01"},
+		{stdlib.DateUS, 0, "3/4/21", "2021-03-04"},
+		{stdlib.DateUS, 20, "3/4/21", "1921-03-04"},
+		{stdlib.DateUS, 0, "2/29'00", "2000-02-29"},
+		{stdlib.DateEuropean, 0, "31.12.99", "1999-12-31"},
+		{stdlib.DateEuropean, 0, "31/12'04", "2004-12-31"},
+		{stdlib.DateISO, 0, "2021-03-04", "2021-03-04"},
+		{stdlib.DateQuicken, 0, "1/ 2/05", "1905-01-02"},
+		{stdlib.DateQuicken, 0, "1/ 2'05", "2005-01-02"},
+		{stdlib.DateAuto, 0, "31/12/99", "1999-12-31"},
+		{stdlib.DateAuto, 0, "2021-03-04", "2021-03-04"},
+	} {
+		o := stdlib.DateOptions{Format: tc.format, CenturyPivot: tc.pivot}
+		yields, err := o.Parse(tc.input)
+		if err != nil {
+			t.Errorf("%s: input of %q yields error %v: expected value is %q\n", tc.format, tc.input, err, tc.expected)
+		} else if yields.String() != tc.expected {
+			t.Errorf("%s: input of %q yields %q: expected value is %q\n", tc.format, tc.input, yields.String(), tc.expected)
+		}
+	}
+
+	// When an invalid date is parsed
+	// Then it returns an error
+	for _, tc := range []struct {
+		format stdlib.DateFormat
+		input  string
+	}{
+		{stdlib.DateUS, "31/12/99"},
+		{stdlib.DateUS, "2/29'01"},
+		{stdlib.DateUS, "1900/2/29"},
+		{stdlib.DateUS, "12/31'1999"},
+		{stdlib.DateUS, "1/2/345"},
+		{stdlib.DateUS, "ab/cd'ee"},
+		{stdlib.DateEuropean, "12/31/99"},
+		{stdlib.DateISO, "04-03-2021"},
+		{stdlib.DateAuto, "1/2/3/4"},
+	} {
+		o := stdlib.DateOptions{Format: tc.format}
+		if yields, err := o.Parse(tc.input); err == nil {
+			t.Errorf("%s: input of %q yields %q: expected error\n", tc.format, tc.input, yields)
+		}
+	}
+}
+
+func TestDetectDateFormat(t *testing.T) {
+	// Specification: DetectDateFormat
+
+	// When one of the dates can only be European
+	// Then the format is European
+	samples := []string{"1/2/16", "3/4/16", "31/12/16"}
+	if yields := stdlib.DetectDateFormat(samples, stdlib.DateOptions{}); yields != stdlib.DateEuropean {
+		t.Errorf("input of %q yields %s: expected value is %s\n", samples, yields, stdlib.DateEuropean)
+	}
+
+	// When all of the dates could be US or European
+	// Then the format is US
+	samples = []string{"1/2/16", "3/4/16"}
+	if yields := stdlib.DetectDateFormat(samples, stdlib.DateOptions{}); yields != stdlib.DateUS {
+		t.Errorf("input of %q yields %s: expected value is %s\n", samples, yields, stdlib.DateUS)
+	}
+
+	// When the dates are ISO
+	// Then the format is ISO
+	samples = []string{"2016-01-02", "2016-12-31"}
+	if yields := stdlib.DetectDateFormat(samples, stdlib.DateOptions{}); yields != stdlib.DateISO {
+		t.Errorf("input of %q yields %s: expected value is %s\n", samples, yields, stdlib.DateISO)
+	}
+}
@@ -0,0 +1,261 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package stdlib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a QIF file.
+type Encoding int
+
+const (
+	EncodingAuto        Encoding = iota // detect the encoding from the data
+	EncodingUTF8                        // UTF-8, with or without a byte order mark
+	EncodingUTF16LE                     // UTF-16, little endian
+	EncodingUTF16BE                     // UTF-16, big endian
+	EncodingWindows1252                 // Windows code page 1252, what Quicken for Windows writes
+	EncodingLatin1                      // ISO-8859-1
+)
+
+// ParseEncoding translates the name of an encoding to an Encoding.
+// It accepts the common aliases (eg, "utf8", "cp1252" and "latin1").
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "auto":
+		return EncodingAuto, nil
+	case "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "utf-16le", "utf16le":
+		return EncodingUTF16LE, nil
+	case "utf-16be", "utf16be":
+		return EncodingUTF16BE, nil
+	case "windows-1252", "cp1252":
+		return EncodingWindows1252, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		return EncodingLatin1, nil
+	}
+	return EncodingAuto, fmt.Errorf("unknown encoding %q", name)
+}
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingAuto:
+		return "auto"
+	case EncodingUTF8:
+		return "utf-8"
+	case EncodingUTF16LE:
+		return "utf-16le"
+	case EncodingUTF16BE:
+		return "utf-16be"
+	case EncodingWindows1252:
+		return "windows-1252"
+	case EncodingLatin1:
+		return "iso-8859-1"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DetectEncoding guesses the encoding of the input. A byte order mark
+// always wins. Without one, input with many NUL bytes in either the odd
+// or even positions, and few in the other, is UTF-16. Characters such as
+// U+2000 put a NUL in the other position, so a few are allowed. Otherwise, valid UTF-8 is UTF-8. Anything
+// else is Windows-1252 if it uses the bytes that Windows-1252 assigns to
+// punctuation (0x80 to 0x9F) and ISO-8859-1 if it doesn't. The two are
+// the same for every other byte.
+func DetectEncoding(input []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(input, bomUTF8):
+		return EncodingUTF8
+	case bytes.HasPrefix(input, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(input, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+
+	// QIF is mostly ASCII, so UTF-16 has a NUL in every other byte
+	sample := input
+	if len(sample) > 1024 {
+		sample = sample[:1024]
+	}
+	var even, odd int
+	for i, b := range sample {
+		if b == 0 && i%2 == 0 {
+			even++
+		} else if b == 0 {
+			odd++
+		}
+	}
+	if pairs := len(sample) / 2; pairs != 0 {
+		if odd > pairs/2 && even*8 < odd {
+			return EncodingUTF16LE
+		} else if even > pairs/2 && odd*8 < even {
+			return EncodingUTF16BE
+		}
+	}
+
+	if utf8.Valid(input) {
+		return EncodingUTF8
+	}
+	for _, b := range input {
+		if 0x80 <= b && b <= 0x9F {
+			return EncodingWindows1252
+		}
+	}
+	return EncodingLatin1
+}
+
+// Transcode returns a copy of the input converted from the encoding to
+// UTF-8. The byte order mark, if there is one, is removed. With
+// EncodingAuto, the encoding is detected first. It returns the encoding
+// that was used.
+func Transcode(input []byte, e Encoding) ([]byte, Encoding, error) {
+	if e == EncodingAuto {
+		e = DetectEncoding(input)
+	}
+	switch e {
+	case EncodingUTF8:
+		output := bytes.TrimPrefix(input, bomUTF8)
+		if !utf8.Valid(output) {
+			return nil, e, fmt.Errorf("utf8: invalid utf-8 character")
+		}
+		return append([]byte{}, output...), e, nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+		output, err := ioutil.ReadAll(NewUTF16Reader(bytes.NewReader(input), e == EncodingUTF16BE))
+		return output, e, err
+	case EncodingWindows1252, EncodingLatin1:
+		output := make([]byte, 0, len(input))
+		for _, b := range input {
+			output = appendSingleByte(output, b, e)
+		}
+		return output, e, nil
+	}
+	return nil, e, fmt.Errorf("unknown encoding %q", e)
+}
+
+// appendSingleByte appends the UTF-8 encoding of a Windows-1252 or
+// ISO-8859-1 byte. The five bytes that Windows-1252 doesn't define are
+// translated as if they were ISO-8859-1, which is what Windows does.
+func appendSingleByte(output []byte, b byte, e Encoding) []byte {
+	if b < 0x80 {
+		return append(output, b)
+	}
+	r := rune(b)
+	if e == EncodingWindows1252 && 0x80 <= b && b <= 0x9F && cp1252[b-0x80] != 0 {
+		r = cp1252[b-0x80]
+	}
+	var buf [utf8.UTFMax]byte
+	return append(output, buf[:utf8.EncodeRune(buf[:], r)]...)
+}
+
+// NewUTF16Reader returns a reader that translates UTF-16 input to UTF-8.
+// A leading byte order mark is removed.
+func NewUTF16Reader(r io.Reader, bigEndian bool) io.Reader {
+	return &utf16Reader{r: r, bigEndian: bigEndian, buf: make([]byte, 4096)}
+}
+
+// utf16Reader holds the code units that haven't been translated (a
+// trailing odd byte or the first half of a surrogate pair) and the
+// translated bytes that haven't been returned.
+type utf16Reader struct {
+	r         io.Reader
+	bigEndian bool
+	buf       []byte
+	in, out   []byte
+	started   bool // true once the byte order mark has been checked
+	err       error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err == io.EOF && len(u.in) != 0 {
+			u.err = fmt.Errorf("utf16: input ends in the middle of a character")
+		}
+		if u.err != nil {
+			return 0, u.err
+		}
+		var n int
+		n, u.err = u.r.Read(u.buf)
+		u.in = append(u.in, u.buf[:n]...)
+		if err := u.translate(); err != nil {
+			u.err = err
+		}
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// translate moves every complete character from in to out.
+func (u *utf16Reader) translate() error {
+	var buf [utf8.UTFMax]byte
+	for len(u.in) >= 2 {
+		r, w := u.unit(u.in), 2
+		if utf16.IsSurrogate(r) {
+			if len(u.in) < 4 {
+				break
+			}
+			r, w = utf16.DecodeRune(r, u.unit(u.in[2:])), 4
+			if r == utf8.RuneError {
+				return fmt.Errorf("utf16: invalid surrogate pair")
+			}
+		}
+		u.in = u.in[w:]
+		if !u.started && r == 0xFEFF {
+			u.started = true
+			continue
+		}
+		u.started = true
+		u.out = append(u.out, buf[:utf8.EncodeRune(buf[:], r)]...)
+	}
+	return nil
+}
+
+// unit returns the first code unit of the input.
+func (u *utf16Reader) unit(b []byte) rune {
+	if u.bigEndian {
+		return rune(b[0])<<8 | rune(b[1])
+	}
+	return rune(b[1])<<8 | rune(b[0])
+}
+
+// cp1252 maps bytes 0x80 to 0x9F to the characters that Windows-1252
+// assigns to them. Zero means the byte is undefined.
+var cp1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
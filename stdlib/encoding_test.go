@@ -0,0 +1,101 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ */
+
+package stdlib_test
+
+import (
+	"github.com/maloquacious/qif/stdlib"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	// Specification: Encoding
+
+	for _, tc := range []struct {
+		id       int
+		input    string
+		encoding stdlib.Encoding
+		expected string
+	}{
+		{1, "PCafé\n", stdlib.EncodingUTF8, "PCafé\n"},
+		{2, "\xef\xbb\xbfPCafé\n", stdlib.EncodingUTF8, "PCafé\n"},
+		{3, "PCaf\xe9\n", stdlib.EncodingLatin1, "PCafé\n"},
+		{4, "P\x93Caf\xe9\x94 \x80\n", stdlib.EncodingWindows1252, "P“Café” €\n"},
+		{5, "\xff\xfeP\x00C\x00a\x00f\x00\xe9\x00\n\x00", stdlib.EncodingUTF16LE, "PCafé\n"},
+		{6, "\xfe\xff\x00P\x00C\x00a\x00f\x00\xe9\x00\n", stdlib.EncodingUTF16BE, "PCafé\n"},
+		{7, "P\x00C\x00a\x00f\x00\xe9\x00\n\x00", stdlib.EncodingUTF16LE, "PCafé\n"},
+		{8, "\xff\xfeP\x00=\xd8\x00\xde\n\x00", stdlib.EncodingUTF16LE, "P😀\n"},
+		{9, utf16le("PAcme\u2000Hardware Store\n"), stdlib.EncodingUTF16LE, "PAcme\u2000Hardware Store\n"},
+		{10, utf16be("PAcme\u2000Hardware Store\n"), stdlib.EncodingUTF16BE, "PAcme\u2000Hardware Store\n"},
+	} {
+		// When the encoding is detected
+		// Then the expected encoding is found
+		if yields := stdlib.DetectEncoding([]byte(tc.input)); yields != tc.encoding {
+			t.Errorf("%d: detect yields %s: expected %s\n", tc.id, yields, tc.encoding)
+		}
+
+		// When the input is transcoded
+		// Then it is converted to UTF-8 without a byte order mark
+		output, _, err := stdlib.Transcode([]byte(tc.input), stdlib.EncodingAuto)
+		if err != nil {
+			t.Errorf("%d: transcode yields %v: expected nil\n", tc.id, err)
+		} else if string(output) != tc.expected {
+			t.Errorf("%d: transcode yields %q: expected %q\n", tc.id, output, tc.expected)
+		}
+	}
+
+	// When UTF-16 input has an unpaired surrogate
+	// Then it returns an error
+	if _, _, err := stdlib.Transcode([]byte("\xff\xfe\x00\xdcP\x00"), stdlib.EncodingAuto); err == nil {
+		t.Errorf("unpaired surrogate: expected error: yields nil\n")
+	}
+
+	// When the name of an encoding is parsed
+	// Then aliases are accepted
+	for _, name := range []string{"cp1252", "Windows-1252"} {
+		if yields, err := stdlib.ParseEncoding(name); err != nil || yields != stdlib.EncodingWindows1252 {
+			t.Errorf("%q: yields %s, %v: expected %s\n", name, yields, err, stdlib.EncodingWindows1252)
+		}
+	}
+}
+
+// utf16le returns s encoded as UTF-16LE without a byte order mark. It
+// only handles characters in the Basic Multilingual Plane.
+func utf16le(s string) string {
+	var b []byte
+	for _, r := range s {
+		b = append(b, byte(r), byte(r>>8))
+	}
+	return string(b)
+}
+
+// utf16be returns s encoded as UTF-16BE without a byte order mark. It
+// only handles characters in the Basic Multilingual Plane.
+func utf16be(s string) string {
+	var b []byte
+	for _, r := range s {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return string(b)
+}
@@ -38,6 +38,8 @@ import (
 // `01` and ` 1` are both the first day of the month. The year must be two
 // digits, and we're assuming it is always in the 21st century (eg, `16` is
 // converted to 2016, not 1916).
+//
+// Deprecated: Use DateOptions.Parse, which accepts other formats and centuries.
 func Date(b []byte) string {
 	atoi := func(b []byte) int {
 		return int(b[0] - '0')
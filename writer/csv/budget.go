@@ -0,0 +1,137 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"io"
+	"strings"
+	"time"
+)
+
+// Budget is a grid of budget amounts with a row for each category and a
+// column for each month.
+type Budget struct {
+	Budgets []*normalizer.Budget
+}
+
+// TranslateBudget returns the budgets from the category list.
+func TranslateBudget(r *reader.Reader) (*Budget, error) {
+	var b Budget
+	if r.Categories != nil {
+		var err error
+		if b.Budgets, err = normalizer.Budgets(r.Categories.Records); err != nil {
+			return nil, err
+		}
+	}
+	return &b, nil
+}
+
+func (b *Budget) Write(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	record := []string{"LINE", "CATEGORY", "INCOME"}
+	for month := time.January; month <= time.December; month++ {
+		record = append(record, strings.ToUpper(month.String()[:3]))
+	}
+	record = append(record, "TOTAL")
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	for _, budget := range b.Budgets {
+		record[0] = fmt.Sprintf("%d", budget.Line)
+		record[1] = budget.Category
+		record[2] = fmt.Sprintf("%v", budget.IsIncome)
+		for i, amount := range budget.Months {
+			record[3+i] = amount.String()
+		}
+		record[15] = budget.Total().String()
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("csv: wrote     %8d budgets\n", len(b.Budgets))
+
+	return nil
+}
+
+// BudgetReport compares the budget with the actual amounts for a year.
+type BudgetReport struct {
+	Year      int
+	Variances []*normalizer.Variance
+}
+
+// TranslateBudgetReport returns the budget-vs-actual report for a year.
+func TranslateBudgetReport(r *reader.Reader, year int) (*BudgetReport, error) {
+	budget, err := TranslateBudget(r)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	return &BudgetReport{Year: year, Variances: normalizer.BudgetVsActual(budget.Budgets, transactions, year)}, nil
+}
+
+func (b *BudgetReport) Write(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	record := []string{"YEAR", "MONTH", "CATEGORY", "BUDGET", "ACTUAL", "DIFFERENCE"}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	for _, v := range b.Variances {
+		record[0] = fmt.Sprintf("%d", b.Year)
+		record[1] = fmt.Sprintf("%02d", int(v.Month))
+		record[2] = v.Category
+		record[3] = v.Budget.String()
+		record[4] = v.Actual.String()
+		record[5] = v.Difference.String()
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("csv: wrote     %8d budget variances\n", len(b.Variances))
+
+	return nil
+}
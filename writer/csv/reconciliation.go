@@ -0,0 +1,78 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/maloquacious/qif/balance"
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/reader"
+	"io"
+)
+
+// Reconciliation lists the reconciled, cleared and outstanding totals of
+// each account.
+type Reconciliation struct {
+	Summaries []balance.Summary
+}
+
+// TranslateReconciliation returns the totals for each account.
+func TranslateReconciliation(r *reader.Reader) (*Reconciliation, error) {
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	return &Reconciliation{Summaries: balance.Summarize(balance.Registers(transactions))}, nil
+}
+
+func (rc *Reconciliation) Write(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	record := []string{"ACCOUNT", "RECONCILED", "CLEARED", "OUTSTANDING", "BALANCE"}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	for _, s := range rc.Summaries {
+		record[0] = s.Account
+		record[1] = s.Reconciled.String()
+		record[2] = s.Cleared.String()
+		record[3] = s.Outstanding.String()
+		record[4] = s.Balance().String()
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("csv: wrote     %8d account reconciliations\n", len(rc.Summaries))
+
+	return nil
+}
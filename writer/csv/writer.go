@@ -30,9 +30,10 @@ import (
 	"fmt"
 	"github.com/maloquacious/qif/normalizer"
 	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/account"
+	"github.com/maloquacious/qif/stdlib"
 	"io"
 	"sort"
-	"strings"
 )
 
 type CSV struct {
@@ -50,18 +51,18 @@ type Account struct {
 	CreditLimit          string
 	Description          string
 	StatementBalance     string
-	StatementBalanceDate string
+	StatementBalanceDate stdlib.CivilDate
 }
 
 type Transaction struct {
 	Line          int
 	Type          string
-	Date          string
+	Date          stdlib.CivilDate
 	Account       *Account
 	ToAccount     string
 	Amount        string
 	Category      string
-	ClearedStatus string
+	ClearedStatus normalizer.ClearedStatus
 	IsLinked      bool
 	IsZero        bool
 	Memo          string
@@ -73,8 +74,9 @@ type Transaction struct {
 type Split struct {
 	Line     int
 	Account  string
-	Amount   string
+	Amount   stdlib.Amount
 	Category string
+	IsLinked bool
 	IsZero   bool
 	Memo     string
 }
@@ -83,7 +85,11 @@ func Translate(r *reader.Reader) (*CSV, error) {
 	var c CSV
 	c.Map.Accounts = make(map[string]*Account)
 
-	for _, account := range r.Accounts.Records {
+	var accounts []*account.Record
+	if r.Accounts != nil {
+		accounts = r.Accounts.Records
+	}
+	for _, account := range accounts {
 		var typ string
 		switch account.Type {
 		case "Bank":
@@ -101,7 +107,7 @@ func Translate(r *reader.Reader) (*CSV, error) {
 		case "401(k)/403(b)":
 			typ = "RET"
 		default:
-			panic(fmt.Sprintf("assert(account.type != %q)", account.Type))
+			return nil, fmt.Errorf("%d: account: %w %q", account.Line, reader.ErrUnsupportedAccountType, account.Type)
 		}
 		a := &Account{
 			Line:                 account.Line,
@@ -116,7 +122,14 @@ func Translate(r *reader.Reader) (*CSV, error) {
 		c.Map.Accounts[account.Name] = a
 	}
 
-	for _, transaction := range normalizer.Transactions(r.Transactions) {
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range transactions {
+		if c.Map.Accounts[transaction.Account] == nil {
+			return nil, fmt.Errorf("%d: transaction: unknown account %q", transaction.Line, transaction.Account)
+		}
 		xact := &Transaction{
 			Line:          transaction.Line,
 			Account:       c.Map.Accounts[transaction.Account],
@@ -135,6 +148,7 @@ func Translate(r *reader.Reader) (*CSV, error) {
 				Account:  line.Account,
 				Amount:   line.Amount,
 				Category: line.Category,
+				IsLinked: line.IsLinked,
 				IsZero:   line.IsZero,
 				Memo:     line.Memo,
 			}
@@ -175,30 +189,24 @@ func (c *CSV) Write(w io.Writer) error {
 		for _, split := range t.Split {
 			if split.IsZero { // skip splits that have zero amount
 				continue
+			} else if split.IsLinked { // skip transfers that the other account records
+				continue
 			}
 
 			seq++
 
-			amount, flipped := strings.ReplaceAll(split.Amount, ",", ""), false
+			amount, flipped := split.Amount, false
 			if t.Payee == "Opening Balance" && len(t.Split) == 1 {
 				if t.Account.Type == "ASS" || t.Account.Type == "LBT" {
-					if amount == "" || amount == "0.00" {
-						amount, flipped = "0.00", false
-					} else if amount[0] == '-' {
-						amount, flipped = amount[1:], true
-					} else if amount[0] == '+' {
-						amount, flipped = "-"+amount[1:], true
-					} else {
-						amount, flipped = "-"+amount, true
-					}
+					amount, flipped = amount.Neg(), !amount.IsZero()
 				}
 			}
 
 			// transaction
 			record[0] = fmt.Sprintf("%d", t.Line)
 			record[1] = fmt.Sprintf("%d", seq)
-			record[2] = t.Date
-			record[3] = t.ClearedStatus
+			record[2] = t.Date.Format("2006/01/02")
+			record[3] = t.ClearedStatus.String()
 			record[4] = t.RefNo
 			record[5] = t.Payee
 			record[6] = t.Memo
@@ -213,7 +221,7 @@ func (c *CSV) Write(w io.Writer) error {
 			record[11] = split.Account
 			record[12] = split.Category
 			record[13] = split.Memo
-			record[14] = amount
+			record[14] = amount.String()
 			record[15] = fmt.Sprintf("%v", flipped)
 
 			if err := cw.Write(record); err != nil {
@@ -239,10 +247,10 @@ func (c *CSV) Len() int {
 }
 
 func (c *CSV) Less(i, j int) bool {
-	if c.Transactions[i].Date < c.Transactions[j].Date {
+	if c.Transactions[i].Date.Before(c.Transactions[j].Date) {
 		return true
 	}
-	if c.Transactions[i].Date > c.Transactions[j].Date {
+	if c.Transactions[i].Date.After(c.Transactions[j].Date) {
 		return false
 	}
 	if c.Transactions[i].Account.Name < c.Transactions[j].Account.Name {
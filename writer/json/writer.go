@@ -30,13 +30,17 @@ import (
 	"fmt"
 	"github.com/maloquacious/qif/normalizer"
 	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/account"
+	"github.com/maloquacious/qif/reader/category"
 	"io"
 )
 
 type JSON struct {
 	Accounts     []Account     `json:"accounts"`
 	Categories   []Category    `json:"categories"`
+	Classes      []Class       `json:"classes,omitempty"`
 	Transactions []Transaction `json:"transactions"`
+	Memorized    []Memorized   `json:"memorized,omitempty"`
 }
 
 type Account struct {
@@ -45,7 +49,7 @@ type Account struct {
 	CreditLimit          string `json:"credit_limit,omitempty"`
 	Description          string `json:"descr,omitempty"`
 	StatementBalance     string `json:"balance,omitempty"`
-	StatementBalanceDate string `json:"statement_date,omitempty"`
+	StatementBalanceDate string `json:"statement_date,omitempty"` // ISO 8601
 }
 
 type Category struct {
@@ -56,10 +60,15 @@ type Category struct {
 	TaxSchedule string `json:"tax_schedule,omitempty"`
 }
 
+type Class struct {
+	Name        string `json:"name"`
+	Description string `json:"descr,omitempty"`
+}
+
 type Transaction struct {
 	Line          int     `json:"line,omitempty"`
 	Type          string  `json:"type,omitempty"`
-	Date          string  `json:"date,omitempty"`
+	Date          string  `json:"date,omitempty"` // ISO 8601
 	Account       string  `json:"account,omitempty"`
 	ToAccount     string  `json:"to_account,omitempty"`
 	Amount        string  `json:"amount,omitempty"`
@@ -71,6 +80,30 @@ type Transaction struct {
 	Split         []Split `json:"lines,omitempty"`
 }
 
+type Memorized struct {
+	Line          int           `json:"line,omitempty"`
+	Type          string        `json:"type"`
+	Payee         string        `json:"payee,omitempty"`
+	Address       []string      `json:"address,omitempty"`
+	Amount        string        `json:"amount,omitempty"`
+	Category      string        `json:"category,omitempty"`
+	ToAccount     string        `json:"to_account,omitempty"`
+	ClearedStatus string        `json:"cleared_status,omitempty"`
+	Memo          string        `json:"memo,omitempty"`
+	Split         []Split       `json:"lines,omitempty"`
+	Amortization  *Amortization `json:"amortization,omitempty"`
+}
+
+type Amortization struct {
+	FirstPaymentDate string `json:"first_payment_date,omitempty"` // ISO 8601
+	TotalYears       int    `json:"total_years,omitempty"`
+	PaymentsMade     int    `json:"payments_made,omitempty"`
+	PeriodsPerYear   int    `json:"periods_per_year,omitempty"`
+	InterestRate     string `json:"interest_rate,omitempty"`
+	CurrentBalance   string `json:"current_balance,omitempty"`
+	OriginalAmount   string `json:"original_amount,omitempty"`
+}
+
 type Split struct {
 	Line     int    `json:"line,omitempty"`
 	Account  string `json:"account,omitempty"`
@@ -82,7 +115,11 @@ type Split struct {
 func Translate(r *reader.Reader) (*JSON, error) {
 	var j JSON
 
-	for _, account := range r.Accounts.Records {
+	var accounts []*account.Record
+	if r.Accounts != nil {
+		accounts = r.Accounts.Records
+	}
+	for _, account := range accounts {
 		var typ string
 		switch account.Type {
 		case "Bank":
@@ -100,7 +137,7 @@ func Translate(r *reader.Reader) (*JSON, error) {
 		case "401(k)/403(b)":
 			typ = "retirement"
 		default:
-			panic(fmt.Sprintf("assert(account.type != %q)", account.Type))
+			return nil, fmt.Errorf("%d: account: %w %q", account.Line, reader.ErrUnsupportedAccountType, account.Type)
 		}
 		j.Accounts = append(j.Accounts, Account{
 			Type:                 typ,
@@ -108,11 +145,15 @@ func Translate(r *reader.Reader) (*JSON, error) {
 			CreditLimit:          account.CreditLimit,
 			Description:          account.Description,
 			StatementBalance:     account.StatementBalance,
-			StatementBalanceDate: account.StatementBalanceDate,
+			StatementBalanceDate: account.StatementBalanceDate.String(),
 		})
 	}
 
-	for _, category := range r.Categories.Records {
+	var categories []*category.Record
+	if r.Categories != nil {
+		categories = r.Categories.Records
+	}
+	for _, category := range categories {
 		j.Categories = append(j.Categories, Category{
 			Name:        category.Name,
 			Description: category.Description,
@@ -122,13 +163,23 @@ func Translate(r *reader.Reader) (*JSON, error) {
 		})
 	}
 
-	for _, transaction := range normalizer.Transactions(r.Transactions) {
+	if r.Classes != nil {
+		for _, class := range r.Classes.Records {
+			j.Classes = append(j.Classes, Class{Name: class.Name, Description: class.Description})
+		}
+	}
+
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range transactions {
 		xact := Transaction{
 			Line:          transaction.Line,
 			Type:          transaction.Type,
 			Account:       transaction.Account,
-			ClearedStatus: transaction.ClearedStatus,
-			Date:          transaction.Date,
+			ClearedStatus: cleared(transaction.ClearedStatus),
+			Date:          transaction.Date.String(),
 			Memo:          transaction.Memo,
 			Payee:         transaction.Payee,
 			RefNo:         transaction.RefNo,
@@ -137,7 +188,7 @@ func Translate(r *reader.Reader) (*JSON, error) {
 			split := Split{
 				Line:     line.Line,
 				Account:  line.Account,
-				Amount:   line.Amount,
+				Amount:   line.Amount.String(),
 				Category: line.Category,
 				Memo:     line.Memo,
 			}
@@ -146,9 +197,57 @@ func Translate(r *reader.Reader) (*JSON, error) {
 		j.Transactions = append(j.Transactions, xact)
 	}
 
+	memorized, err := normalizer.MemorizedPayees(r.Memorized, r.Dates)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range memorized {
+		payee := Memorized{
+			Line:          m.Line,
+			Type:          string(m.Type),
+			Payee:         m.Payee,
+			Address:       m.Address,
+			Amount:        m.Total.String(),
+			Category:      m.Category,
+			ToAccount:     m.ToAccount,
+			ClearedStatus: cleared(m.ClearedStatus),
+			Memo:          m.Memo,
+		}
+		for _, line := range m.Split {
+			payee.Split = append(payee.Split, Split{
+				Line:     line.Line,
+				Account:  line.Account,
+				Amount:   line.Amount.String(),
+				Category: line.Category,
+				Memo:     line.Memo,
+			})
+		}
+		if a := m.Amortization; a != nil {
+			payee.Amortization = &Amortization{
+				FirstPaymentDate: a.FirstPaymentDate.String(),
+				TotalYears:       a.TotalYears,
+				PaymentsMade:     a.PaymentsMade,
+				PeriodsPerYear:   a.PeriodsPerYear,
+				InterestRate:     a.InterestRate.String(),
+				CurrentBalance:   a.CurrentBalance.String(),
+				OriginalAmount:   a.OriginalAmount.String(),
+			}
+		}
+		j.Memorized = append(j.Memorized, payee)
+	}
+
 	return &j, nil
 }
 
+// cleared returns the name of the status. Uncleared is left empty so
+// that it is omitted.
+func cleared(status normalizer.ClearedStatus) string {
+	if status == normalizer.Uncleared {
+		return ""
+	}
+	return status.String()
+}
+
 func (j *JSON) Write(w io.Writer) error {
 	buf, err := json.MarshalIndent(j, "", "  ")
 	if err != nil {
@@ -163,6 +262,8 @@ func (j *JSON) Write(w io.Writer) error {
 	}
 	fmt.Printf("json: wrote %8d accounts\n", len(j.Accounts))
 	fmt.Printf("json: wrote %8d categories\n", len(j.Categories))
+	fmt.Printf("json: wrote %8d classes\n", len(j.Classes))
 	fmt.Printf("json: wrote %8d transactions\n", len(j.Transactions))
+	fmt.Printf("json: wrote %8d memorized\n", len(j.Memorized))
 	return nil
 }
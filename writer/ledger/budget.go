@@ -0,0 +1,78 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/stdlib"
+	"time"
+)
+
+// budgetAccount balances the budget's periodic transactions.
+const budgetAccount = "Assets:Budget"
+
+// budgetEntries translates budgets to periodic transactions for ledger's
+// --budget reports. If every category has the same budget each month,
+// there is a single "~ Monthly" transaction. Otherwise there is one for
+// each month of the year. Like other transactions, the amounts have the
+// opposite of the QIF sign.
+func budgetEntries(budgets []*normalizer.Budget, year int) []*Periodic {
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	level := true
+	for _, b := range budgets {
+		for _, amount := range b.Months {
+			if amount.Cmp(b.Months[0]) != 0 {
+				level = false
+			}
+		}
+	}
+
+	var entries []*Periodic
+	for month := time.January; month <= time.December; month++ {
+		p := &Periodic{Line: budgets[0].Line, Payee: "Budget", Bucket: budgetAccount}
+		if level {
+			p.Period = "Monthly"
+		} else {
+			from := stdlib.CivilDate{Year: year, Month: month, Day: 1}
+			p.Period = "Monthly from " + from.Format("2006/01/02") + " to " + from.AddMonths(1).Format("2006/01/02")
+		}
+		for _, b := range budgets {
+			amount := b.Months[month-1]
+			if !amount.IsZero() {
+				p.Lines = append(p.Lines, &Line{Line: b.Line, Source: "budget", Category: b.Category, Amount: amount.Neg()})
+			}
+		}
+		if len(p.Lines) != 0 {
+			entries = append(entries, p)
+		}
+		if level {
+			break
+		}
+	}
+	return entries
+}
@@ -26,6 +26,7 @@ package ledger
 
 import (
 	"fmt"
+	"github.com/maloquacious/qif/stdlib"
 	"io"
 	"sort"
 	"strings"
@@ -33,19 +34,21 @@ import (
 
 type Entry struct {
 	Line        int
+	IsLinked    bool // the other account records the entry
 	IsZero      bool
 	Account     string
 	AccountType string
-	Date        string
+	Date        stdlib.CivilDate
 	Cleared     string
 	RefNo       string
 	Payee       string
 	Memo        string
 	Lines       Lines
+	Bucket      string // the account that balances the entry; defaults to Account
 }
 
 func (e *Entry) Sort() {
-	sort.Sort(e.Lines)
+	sort.Stable(e.Lines)
 }
 
 func (e *Entry) Write(w io.Writer) error {
@@ -65,7 +68,7 @@ func (e *Entry) Write(w io.Writer) error {
 		crp = fmt.Sprintf("  %s", payee)
 	}
 
-	_, err := fmt.Fprintf(w, "%s %-59s ;; %6d %-7s %s\n", e.Date, crp, e.Line, e.AccountType, e.Account)
+	_, err := fmt.Fprintf(w, "%s %-59s ;; %6d %-7s %s\n", e.Date.Format("2006/01/02"), crp, e.Line, e.AccountType, e.Account)
 	if err != nil {
 		return err
 	}
@@ -92,6 +95,9 @@ func (e *Entry) Write(w io.Writer) error {
 
 	// add a bucket to balance
 	bucket := e.Account
+	if e.Bucket != "" {
+		bucket = e.Bucket
+	}
 	if e.Payee == "Opening Balance" && len(e.Lines) == 1 {
 		bucket = "Equity:Opening Balances"
 	} else if strings.Index(bucket, "  ") != -1 {
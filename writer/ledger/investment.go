@@ -0,0 +1,212 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger
+
+import (
+	"github.com/maloquacious/qif/normalizer"
+	"github.com/maloquacious/qif/stdlib"
+	"sort"
+)
+
+// accounts that investment transactions are posted to when the QIF
+// record doesn't name a category.
+const (
+	commissionAccount = "Expenses:Commissions"
+	stockSplitAccount = "Equity:Stock Splits"
+	transferAccount   = "Equity:Transfers"
+)
+
+// defaultCategory is the income or expense account for an action that
+// doesn't have a category.
+var defaultCategory = map[normalizer.Action]string{
+	normalizer.CGLong:   "Income:Capital Gains:Long Term",
+	normalizer.CGLongX:  "Income:Capital Gains:Long Term",
+	normalizer.CGMid:    "Income:Capital Gains:Mid Term",
+	normalizer.CGMidX:   "Income:Capital Gains:Mid Term",
+	normalizer.CGShort:  "Income:Capital Gains:Short Term",
+	normalizer.CGShortX: "Income:Capital Gains:Short Term",
+	normalizer.Div:      "Income:Dividends",
+	normalizer.DivX:     "Income:Dividends",
+	normalizer.IntInc:   "Income:Interest",
+	normalizer.IntIncX:  "Income:Interest",
+	normalizer.MargInt:  "Expenses:Margin Interest",
+	normalizer.MargIntX: "Expenses:Margin Interest",
+	normalizer.MiscExp:  "Expenses:Miscellaneous",
+	normalizer.MiscExpX: "Expenses:Miscellaneous",
+	normalizer.MiscInc:  "Income:Miscellaneous",
+	normalizer.MiscIncX: "Income:Miscellaneous",
+	normalizer.ReinvDiv: "Income:Dividends",
+	normalizer.ReinvInt: "Income:Interest",
+	normalizer.ReinvLg:  "Income:Capital Gains:Long Term",
+	normalizer.ReinvMd:  "Income:Capital Gains:Mid Term",
+	normalizer.ReinvSh:  "Income:Capital Gains:Short Term",
+	normalizer.RtrnCap:  "Income:Return of Capital",
+	normalizer.RtrnCapX: "Income:Return of Capital",
+}
+
+// investmentEntries translates transactions from investment accounts.
+// Shares are posted to the investment account as commodities priced in
+// dollars, so ledger balances the entry with the cost of the shares.
+// Cash is posted to the investment account or, for actions that
+// transfer cash, to the other account.
+//
+// Stock splits are posted as an adjustment to the number of shares held
+// with the other side in an equity account. The QIF quantity is the
+// number of new shares for every ten old shares.
+//
+// Securities are named by their ticker symbol if they have one.
+//
+// Actions that don't move shares or cash (eg, Reminder) are ignored, and
+// so are actions that Quicken doesn't define.
+func investmentEntries(investments []*normalizer.Investment, tickers map[string]string) []*Entry {
+	// stock splits need the number of shares held, so we translate the
+	// transactions in date order.
+	sorted := make([]*normalizer.Investment, len(investments))
+	copy(sorted, investments)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	var entries []*Entry
+	holdings := make(map[[2]string]stdlib.Amount)
+	for _, inv := range sorted {
+		e := &Entry{
+			Line:        inv.Line,
+			IsZero:      true,
+			Account:     inv.Account,
+			AccountType: "Invst",
+			Cleared:     marker(inv.ClearedStatus),
+			Date:        inv.Date,
+			Payee:       inv.Payee,
+			RefNo:       string(inv.Action),
+			Memo:        inv.Memo,
+		}
+		if e.Payee == "" {
+			e.Payee = inv.Security
+		}
+
+		cash := inv.Account
+		if inv.Action.IsTransfer() && inv.Transfer != nil {
+			cash = inv.Transfer.Account
+		} else if inv.Action == normalizer.XIn || inv.Action == normalizer.XOut {
+			cash = transferAccount
+		}
+		_, isIncomeOrExpense := defaultCategory[inv.Action]
+		category := inv.Category
+		if category == "" {
+			category = defaultCategory[inv.Action]
+		}
+
+		security := inv.Security
+		if ticker, ok := tickers[security]; ok {
+			security = ticker
+		}
+
+		key := [2]string{inv.Account, security}
+		shares := func(quantity, price stdlib.Amount) {
+			holdings[key] = holdings[key].Add(quantity)
+			e.Lines = append(e.Lines, &Line{
+				Line:      inv.Line,
+				Source:    "security",
+				Category:  inv.Account,
+				Commodity: security,
+				Quantity:  quantity,
+				Price:     price,
+				IsZero:    quantity.IsZero(),
+			})
+		}
+		dollars := func(account, source string, amount stdlib.Amount) {
+			if !amount.IsZero() {
+				e.Lines = append(e.Lines, &Line{Line: inv.Line, Source: source, Category: account, Amount: amount})
+			}
+		}
+
+		switch {
+		case inv.Action.IsBuy():
+			shares(inv.Quantity, inv.Price)
+			dollars(commissionAccount, "commission", inv.Commission)
+			e.Bucket = cash
+			if isIncomeOrExpense { // reinvested income
+				e.Bucket = category
+			}
+		case inv.Action.IsSell():
+			shares(inv.Quantity.Neg(), inv.Price)
+			dollars(commissionAccount, "commission", inv.Commission)
+			e.Bucket = cash
+		case inv.Action == normalizer.ShrsIn:
+			shares(inv.Quantity, inv.Price)
+			e.Bucket = transferAccount
+		case inv.Action == normalizer.ShrsOut:
+			shares(inv.Quantity.Neg(), inv.Price)
+			e.Bucket = transferAccount
+		case inv.Action == normalizer.StkSplit:
+			held := holdings[key]
+			split, _ := held.Mul(inv.Quantity).Div(stdlib.NewAmount(10))
+			shares(split.Sub(held), stdlib.Amount{})
+			e.Bucket = stockSplitAccount
+		case inv.Action == normalizer.XIn:
+			dollars(inv.Account, "account", inv.Total)
+			e.Bucket = cash
+		case inv.Action == normalizer.XOut:
+			dollars(inv.Account, "account", inv.Total.Neg())
+			e.Bucket = cash
+		case inv.Action == normalizer.Cash:
+			// the amount is signed and the other side is the transfer
+			// account or the category
+			dollars(inv.Account, "account", inv.Total)
+			e.Bucket = inv.Category
+			if inv.Transfer != nil {
+				e.Bucket = inv.Transfer.Account
+			} else if e.Bucket == "" {
+				e.Bucket = "Missing Category"
+			}
+		case isIncomeOrExpense && isExpense(inv.Action):
+			dollars(category, "category", inv.Total)
+			e.Bucket = cash
+		case isIncomeOrExpense:
+			dollars(cash, "account", inv.Total)
+			e.Bucket = category
+		default:
+			continue
+		}
+
+		for _, line := range e.Lines {
+			if !line.IsZero {
+				e.IsZero = false
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// isExpense returns true if the action pays an expense from cash.
+func isExpense(action normalizer.Action) bool {
+	switch action {
+	case normalizer.MargInt, normalizer.MargIntX, normalizer.MiscExp, normalizer.MiscExpX:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger_test
+
+import (
+	"strings"
+	"testing"
+)
+
+// entry returns the postings of the entry on the date with the spacing
+// collapsed and the comments removed.
+func entry(ledger, date string) string {
+	var postings []string
+	found := false
+	for _, line := range strings.Split(ledger, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			found = strings.HasPrefix(line, date+" ")
+			continue
+		} else if !found {
+			continue
+		}
+		if i := strings.Index(line, ";;"); i != -1 {
+			line = line[:i]
+		}
+		postings = append(postings, strings.Join(strings.Fields(line), " "))
+	}
+	return strings.Join(postings, ", ")
+}
+
+func TestInvestments(t *testing.T) {
+	// Specification: Investment entries
+
+	// Given a brokerage register that buys, sells, splits, reinvests
+	// and moves cash to and from checking
+	output := translate(t, "", `D1/ 4'16
+NBuy
+YACME
+I12.40
+Q100
+O4.45
+T1,244.45
+^
+D2/ 1'16
+NSell
+YACME
+I15.00
+Q50
+O5.00
+T745.00
+^
+D3/ 1'16
+NStkSplit
+YACME
+Q20
+^
+D4/ 1'16
+NReinvDiv
+YACME
+I10.00
+Q5
+T50.00
+^
+D5/ 1'16
+NSellX
+YACME
+I20.00
+Q10
+T200.00
+L[Checking]
+$200.00
+^
+D6/ 1'16
+NBuyX
+YACME
+I20.00
+Q10
+T200.00
+L[Checking]
+$200.00
+^
+`)
+
+	// When it is translated to ledger
+	for _, tc := range []struct {
+		spec   string
+		date   string
+		expect string
+	}{
+		// Then a buy posts the shares at their price and the commission,
+		// and the brokerage cash balances it
+		{"buy", "2016/01/04", "Brokerage 100.00 ACME @ $12.40, Expenses:Commissions $4.45, Brokerage"},
+		// And a sell removes the shares
+		{"sell", "2016/02/01", "Brokerage -50.00 ACME @ $15.00, Expenses:Commissions $5.00, Brokerage"},
+		// And a two-for-one split doubles the 50 shares that are still held
+		{"stock split", "2016/03/01", "Brokerage 50.00 ACME, Equity:Stock Splits"},
+		// And a reinvested dividend buys shares with dividend income
+		{"reinvested dividend", "2016/04/01", "Brokerage 5.00 ACME @ $10.00, Income:Dividends"},
+		// And a sell that transfers the cash puts it in checking
+		{"sell and transfer", "2016/05/01", "Brokerage -10.00 ACME @ $20.00, Checking"},
+		// And a buy that transfers the cash takes it from checking
+		{"buy and transfer", "2016/06/01", "Brokerage 10.00 ACME @ $20.00, Checking"},
+	} {
+		if yields := entry(output, tc.date); yields != tc.expect {
+			t.Errorf("%s: yields %q: expected %q\n", tc.spec, yields, tc.expect)
+		}
+	}
+}
+
+func TestStockSplitHoldings(t *testing.T) {
+	// Specification: Stock splits use the shares held on the date
+
+	// Given shares bought after a split that is listed first in the register
+	output := translate(t, "", `D3/ 1'16
+NStkSplit
+YACME
+Q30
+^
+D1/ 4'16
+NShrsIn
+YACME
+Q10
+^
+D6/ 1'16
+NShrsIn
+YACME
+Q10
+^
+`)
+
+	// When it is translated to ledger
+	// Then the split only applies to the shares held before it
+	if yields, expect := entry(output, "2016/03/01"), "Brokerage 20.00 ACME, Equity:Stock Splits"; yields != expect {
+		t.Errorf("stock split: yields %q: expected %q\n", yields, expect)
+	}
+}
+
+func TestAmountMismatch(t *testing.T) {
+	// Specification: Buys whose amounts don't add up
+
+	// Given a buy whose amount is off by more than a cent and a sell
+	output := translate(t, "", `D1/ 4'16
+NBuy
+YACME
+I12.40
+Q100
+O4.45
+T1,300.00
+^
+D2/ 1'16
+NSell
+YACME
+I15.00
+Q50
+O5.00
+T745.00
+^
+`)
+
+	// When it is translated to ledger
+	// Then the mismatched buy is still written
+	if yields, expect := entry(output, "2016/01/04"), "Brokerage 100.00 ACME @ $12.40, Expenses:Commissions $4.45, Brokerage"; yields != expect {
+		t.Errorf("buy: yields %q: expected %q\n", yields, expect)
+	}
+
+	// And so is the sell
+	if yields, expect := entry(output, "2016/02/01"), "Brokerage -50.00 ACME @ $15.00, Expenses:Commissions $5.00, Brokerage"; yields != expect {
+		t.Errorf("sell: yields %q: expected %q\n", yields, expect)
+	}
+}
@@ -31,7 +31,9 @@ import (
 )
 
 type LEDGER struct {
-	Entries []*Entry
+	Periodic []*Periodic // written before the entries
+	Entries  []*Entry
+	Prices   Prices // written after the entries
 }
 
 func (l *LEDGER) Len() int {
@@ -39,10 +41,10 @@ func (l *LEDGER) Len() int {
 }
 
 func (l *LEDGER) Less(i, j int) bool {
-	if l.Entries[i].Date < l.Entries[j].Date {
+	if l.Entries[i].Date.Before(l.Entries[j].Date) {
 		return true
 	}
-	if l.Entries[i].Date > l.Entries[j].Date {
+	if l.Entries[i].Date.After(l.Entries[j].Date) {
 		return false
 	}
 	return l.Entries[i].Line < l.Entries[j].Line
@@ -62,11 +64,27 @@ func (l *LEDGER) Swap(i, j int) {
 func (l *LEDGER) Write(w io.Writer) error {
 	var skipped, written int
 
+	for _, p := range l.Periodic {
+		if err := p.Write(w); err != nil {
+			return err
+		}
+	}
+	if len(l.Periodic) != 0 {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
 	for _, e := range l.Entries {
 		// don't write entries that are missing amounts
 		if e.IsZero {
 			continue
 		}
+		// don't write transfers twice
+		if e.IsLinked {
+			skipped++
+			continue
+		}
 
 		err := e.Write(w)
 		if err != nil {
@@ -75,8 +93,18 @@ func (l *LEDGER) Write(w io.Writer) error {
 		written++
 	}
 
+	fmt.Printf("ledger: wrote     %8d periodic\n", len(l.Periodic))
 	fmt.Printf("ledger: skipped   %8d entries\n", skipped)
 	fmt.Printf("ledger: wrote     %8d entries\n", written)
 
+	if len(l.Prices) != 0 {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+		if err := l.Prices.Write(w); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
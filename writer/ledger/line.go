@@ -26,18 +26,24 @@ package ledger
 
 import (
 	"fmt"
+	"github.com/maloquacious/qif/stdlib"
 	"io"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 type Lines []*Line
 
 type Line struct {
-	Line     int
-	Source   string
-	Category string
-	Amount   string
-	IsZero   bool
+	Line      int
+	Source    string
+	Category  string
+	Amount    stdlib.Amount
+	IsZero    bool
+	Commodity string        // set when the line posts shares instead of dollars
+	Quantity  stdlib.Amount // number of shares
+	Price     stdlib.Amount // price per share, if known
 }
 
 func (l Lines) Len() int {
@@ -57,6 +63,25 @@ func (l *Line) Write(w io.Writer) error {
 	if strings.Index(category, "  ") != -1 || strings.HasPrefix(category, "check") {
 		category = strings.ReplaceAll(category, " ", "_")
 	}
-	_, err := fmt.Fprintf(w, "    %-49s  %15s ;; %6d %s\n", category, "$"+l.Amount, l.Line, l.Source)
+	amount := "$" + l.Amount.String()
+	if l.Commodity != "" {
+		amount = l.Quantity.String() + " " + commodity(l.Commodity)
+		if !l.Price.IsZero() {
+			amount += " @ $" + l.Price.String()
+		}
+	}
+	_, err := fmt.Fprintf(w, "    %-49s  %15s ;; %6d %s\n", category, amount, l.Line, l.Source)
 	return err
 }
+
+// commodity quotes the name of a commodity unless it is all letters,
+// since ledger would read digits, spaces and punctuation as part of the
+// amount.
+func commodity(name string) string {
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/normalizer"
+	"io"
+)
+
+// memorizedAccount balances periodic transactions. Quicken doesn't say
+// which account a memorized payee is paid from.
+const memorizedAccount = "Assets:Unknown"
+
+// Periodic is a ledger periodic transaction. It is created from a
+// memorized payee that has an amortization schedule or from a budget.
+type Periodic struct {
+	Line   int
+	Period string // eg, "Monthly from 2016/01/01 to 2046/01/01"
+	Payee  string
+	Lines  Lines
+	Bucket string // the account that balances the transaction
+}
+
+// periodicEntries translates memorized payees to periodic transactions.
+//
+// Payees without an amortization schedule are skipped. They don't say
+// how often they repeat, so there is no period for a periodic
+// transaction. They can't be automated transactions either, since ledger
+// adds the postings of an automated transaction to every transaction
+// that it matches, and the transactions that Quicken filled in from the
+// payee already have those postings.
+func periodicEntries(memorized []*normalizer.MemorizedPayee) []*Periodic {
+	var entries []*Periodic
+	for _, m := range memorized {
+		if m.Amortization == nil || m.Amortization.PeriodsPerYear <= 0 {
+			continue
+		}
+		a := m.Amortization
+		p := &Periodic{Line: m.Line, Period: period(a.PeriodsPerYear), Payee: m.Payee, Bucket: memorizedAccount}
+		if !a.FirstPaymentDate.IsZero() {
+			p.Period += " from " + a.FirstPaymentDate.Format("2006/01/02")
+			if a.TotalYears > 0 {
+				p.Period += " to " + a.FirstPaymentDate.AddMonths(12*a.TotalYears).Format("2006/01/02")
+			}
+		}
+		for _, split := range m.Split {
+			line := &Line{Line: split.Line, Amount: split.Amount.Neg(), IsZero: split.IsZero}
+			line.Category, line.Source = split.Account, "account"
+			if line.Category == "" {
+				line.Category, line.Source = split.Category, "category"
+				if line.Category == "" {
+					line.Category, line.Source = "Missing Category", "none"
+				}
+			}
+			p.Lines = append(p.Lines, line)
+		}
+		entries = append(entries, p)
+	}
+	return entries
+}
+
+// period returns the ledger period expression for a number of payments
+// per year.
+func period(perYear int) string {
+	switch perYear {
+	case 1:
+		return "Yearly"
+	case 2:
+		return "Every 6 months"
+	case 4:
+		return "Quarterly"
+	case 6:
+		return "Bimonthly"
+	case 12:
+		return "Monthly"
+	case 26:
+		return "Biweekly"
+	case 52:
+		return "Weekly"
+	}
+	return fmt.Sprintf("Every %d days", (365+perYear/2)/perYear)
+}
+
+func (p *Periodic) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "~ %-59s ;; %6d %s\n", p.Period, p.Line, p.Payee)
+	if err != nil {
+		return err
+	}
+	for _, l := range p.Lines {
+		if err := l.Write(w); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "    %s\n", p.Bucket)
+	return err
+}
@@ -0,0 +1,70 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemorizedPayees(t *testing.T) {
+	// Specification: Memorized payees
+
+	// Given a memorized loan payment with an amortization schedule and a
+	// memorized payee without one
+	output := translate(t, "", `!Type:Memorized
+KP
+T-1,200.00
+PFirst Mortgage Co
+L[Mortgage]
+1 1/ 1'16
+230
+3360
+412
+53.75
+6250,000.00
+7250,000.00
+^
+KC
+T-54.25
+PGrocer
+LGroceries
+^
+`)
+
+	// When they are translated to ledger
+	// Then the loan payment is a periodic transaction for the term of the loan
+	if expect := "~ Monthly from 2016/01/01 to 2046/01/01"; !strings.Contains(output, expect) {
+		t.Errorf("loan: yields %q: expected %q\n", output, expect)
+	}
+	if lines := postings(output, "Mortgage"); len(lines) != 1 || !strings.Contains(lines[0], "$1200.00") {
+		t.Errorf("loan: yields %q: expected Mortgage $1200.00\n", lines)
+	}
+
+	// And the payee without a schedule is left out
+	if strings.Contains(output, "Grocer") {
+		t.Errorf("grocer: yields %q: expected no entry\n", output)
+	}
+}
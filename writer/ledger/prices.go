@@ -0,0 +1,101 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/stdlib"
+	"io"
+	"sort"
+)
+
+// Price is a market price for a commodity on a date.
+type Price struct {
+	Line      int
+	Date      stdlib.CivilDate
+	Commodity string
+	Price     stdlib.Amount
+}
+
+// Prices is a price database. It is sorted by date and commodity.
+type Prices []*Price
+
+// TranslatePrices translates the records from the Prices section.
+// Records without a valid date are skipped since ledger can't use them.
+func TranslatePrices(r *reader.Reader) (Prices, error) {
+	var prices Prices
+	for _, t := range r.Prices {
+		if t.Date.IsZero() {
+			continue
+		}
+		price, err := stdlib.ParseAmount(t.Price)
+		if err != nil {
+			return nil, fmt.Errorf("%d: price: %w", t.Line, err)
+		}
+		prices = append(prices, &Price{Line: t.Line, Date: t.Date, Commodity: t.Ticker, Price: price})
+	}
+	sort.Sort(prices)
+	return prices, nil
+}
+
+func (p Prices) Len() int {
+	return len(p)
+}
+
+func (p Prices) Less(i, j int) bool {
+	if p[i].Date.Before(p[j].Date) {
+		return true
+	}
+	if p[i].Date.After(p[j].Date) {
+		return false
+	}
+	if p[i].Commodity < p[j].Commodity {
+		return true
+	}
+	if p[i].Commodity > p[j].Commodity {
+		return false
+	}
+	return p[i].Line < p[j].Line
+}
+
+func (p Prices) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// Write writes the prices as ledger P directives, which hledger also
+// reads (eg, `P 2016/09/03 AAPL $107.73`).
+func (p Prices) Write(w io.Writer) error {
+	for _, price := range p {
+		_, err := fmt.Fprintf(w, "P %s %s $%s\n", price.Date.Format("2006/01/02"), commodity(price.Commodity), price.Price)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("ledger: wrote     %8d prices\n", len(p))
+
+	return nil
+}
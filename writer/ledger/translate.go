@@ -29,40 +29,55 @@ import (
 	"fmt"
 	"github.com/maloquacious/qif/normalizer"
 	"github.com/maloquacious/qif/reader"
-	"github.com/maloquacious/qif/stdlib"
 )
 
 func Translate(r *reader.Reader) (*LEDGER, error) {
 	l := &LEDGER{}
 
-	for _, t := range normalizer.Transactions(r.Transactions) {
+	// investment transactions are normalized with the others so that
+	// their transfers are matched, but they are translated separately
+	transactions, err := normalizer.Transactions(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		if t.Type == "Invst" {
+			continue
+		}
+
 		// most transactions in ledger require the opposite of the QIF sign
-		flipSign := doFlipSign(t.Type, t.Payee, len(t.Split))
+		flipSign, err := doFlipSign(t.Type, t.Payee, len(t.Split))
+		if err != nil {
+			return nil, fmt.Errorf("%d: transaction: %w", t.Line, err)
+		}
 
 		e := &Entry{
 			Line:        t.Line,
+			IsLinked:    t.IsLinked,
 			IsZero:      true,
 			Account:     t.Account,
 			AccountType: t.Type,
-			Cleared:     t.ClearedStatus,
+			Cleared:     marker(t.ClearedStatus),
 			Date:        t.Date,
 			Payee:       t.Payee,
 			RefNo:       t.RefNo,
 		}
 
 		for _, split := range t.Split {
+			// the other account records the transfer
+			if split.IsLinked && !t.IsLinked {
+				continue
+			}
+
 			line := &Line{
 				Line:   split.Line,
 				IsZero: split.IsZero,
 			}
 
-			amount := split.Amount
-			if amount == "" {
-				amount = "0.00"
-			} else if flipSign {
-				amount = stdlib.FlipSign(amount)
+			line.Amount = split.Amount
+			if flipSign {
+				line.Amount = line.Amount.Neg()
 			}
-			line.Amount = amount
 
 			if line.Category == "" {
 				line.Category, line.Source = split.Account, "account"
@@ -90,31 +105,83 @@ func Translate(r *reader.Reader) (*LEDGER, error) {
 		l.Entries = append(l.Entries, e)
 	}
 
+	investments, err := normalizer.Investments(r.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	l.Entries = append(l.Entries, investmentEntries(investments, tickers(r))...)
+
+	memorized, err := normalizer.MemorizedPayees(r.Memorized, r.Dates)
+	if err != nil {
+		return nil, err
+	}
+	l.Periodic = periodicEntries(memorized)
+
+	if r.Categories != nil {
+		budgets, err := normalizer.Budgets(r.Categories.Records)
+		if err != nil {
+			return nil, err
+		}
+		l.Periodic = append(l.Periodic, budgetEntries(budgets, normalizer.BudgetYear(r.Transactions))...)
+	}
+
+	if l.Prices, err = TranslatePrices(r); err != nil {
+		return nil, err
+	}
+
 	l.Sort()
 
 	return l, nil
 }
 
+// tickers maps the name of each security to its ticker symbol. The
+// Prices section uses the symbol, so investments must use it too for
+// ledger to value them.
+func tickers(r *reader.Reader) map[string]string {
+	m := make(map[string]string)
+	if r.Securities != nil {
+		for _, s := range r.Securities.Records {
+			if s.Ticker != "" {
+				m[s.Name] = s.Ticker
+			}
+		}
+	}
+	return m
+}
+
+// marker returns the ledger marker for the cleared status. Ledger's
+// cleared marker is for reconciled transactions. Transactions that
+// Quicken has checked off but not reconciled are pending.
+func marker(status normalizer.ClearedStatus) string {
+	switch status {
+	case normalizer.Reconciled:
+		return "*"
+	case normalizer.Cleared:
+		return "!"
+	}
+	return ""
+}
+
 // most transactions in ledger require the opposite of the QIF sign,
 // but a couple don't.
-func doFlipSign(accountType, payee string, numberOfLines int) bool {
+func doFlipSign(accountType, payee string, numberOfLines int) (bool, error) {
 	if payee != "Opening Balance" {
-		return true
+		return true, nil
 	}
 	if numberOfLines != 1 {
-		return true
+		return true, nil
 	}
 	switch accountType {
 	case "Bank":
-		return false
+		return false, nil
 	case "Cash":
-		return false
+		return false, nil
 	case "CCard":
-		return false
+		return false, nil
 	case "Oth A":
-		return false
+		return false, nil
 	case "Oth L":
-		return false
+		return false, nil
 	}
-	panic(fmt.Sprintf("assert(account.type != %q)", accountType))
+	return false, fmt.Errorf("%w %q", reader.ErrUnsupportedAccountType, accountType)
 }
@@ -0,0 +1,214 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ledger_test
+
+import (
+	"bytes"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/writer/ledger"
+	"strings"
+	"testing"
+)
+
+// translate returns the ledger for a checking account and a brokerage
+// account with the given registers.
+func translate(t *testing.T, checking, brokerage string) string {
+	input := `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+NBrokerage
+TInvst
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+` + checking + `!Account
+NBrokerage
+TInvst
+^
+!Type:Invst
+` + brokerage
+	sc, err := scanner.New([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ledger.Translate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// postings returns the posting lines for the account.
+func postings(ledger, account string) []string {
+	var lines []string
+	for _, line := range strings.Split(ledger, "\n") {
+		if strings.HasPrefix(line, "    "+account+" ") || line == "    "+account {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+func TestInvestmentTransfers(t *testing.T) {
+	// Specification: Transfers to investment accounts
+
+	// Given a payment from checking for shares bought in the brokerage
+	// account, recorded in both registers
+	output := translate(t, `D1/ 4'16
+PBuy ACME
+T-1,244.45
+L[Brokerage]
+^
+`, `D1/ 4'16
+NBuyX
+YACME
+I12.40
+Q100
+O4.45
+T1,244.45
+L[Checking]
+$1,244.45
+^
+`)
+
+	// When it is translated to ledger
+	// Then checking is debited once, by the investment entry
+	if lines := postings(output, "Checking"); len(lines) != 1 {
+		t.Errorf("checking: yields %q: expected 1 posting\n", lines)
+	}
+
+	// And the shares are posted to the brokerage account
+	if lines := postings(output, "Brokerage"); len(lines) != 1 || !strings.Contains(lines[0], "100.00 ACME @ $12.40") {
+		t.Errorf("brokerage: yields %q: expected 100.00 ACME @ $12.40\n", lines)
+	}
+}
+
+func TestCashActions(t *testing.T) {
+	// Specification: Cash in investment accounts
+
+	// Given a deposit from checking recorded in both registers, interest
+	// without an action and an action that Quicken doesn't define
+	output := translate(t, `D1/ 5'16
+PDeposit
+T-500.00
+L[Brokerage]
+^
+`, `D1/ 5'16
+NCash
+PDeposit
+T500.00
+L[Checking]
+$500.00
+^
+D1/ 6'16
+PInterest
+T1.25
+LInterest
+^
+D1/ 7'16
+NBuyAndHold
+T100.00
+^
+`)
+
+	// When it is translated to ledger
+	// Then checking is debited once, by the investment entry
+	if lines := postings(output, "Checking"); len(lines) != 1 {
+		t.Errorf("checking: yields %q: expected 1 posting\n", lines)
+	}
+
+	// And the cash is posted to the brokerage account
+	if lines := postings(output, "Brokerage"); len(lines) != 2 || !strings.Contains(lines[0], "$500.00") || !strings.Contains(lines[1], "$1.25") {
+		t.Errorf("brokerage: yields %q: expected $500.00 and $1.25\n", lines)
+	}
+
+	// And the unknown action is skipped
+	if strings.Contains(output, "BuyAndHold") {
+		t.Errorf("unknown: yields %q: expected no entry\n", output)
+	}
+}
+
+func TestClearedMarkers(t *testing.T) {
+	// Specification: Cleared markers
+
+	// Given an uncleared transaction, transactions cleared with `*` and
+	// `c`, and a reconciled transaction
+	output := translate(t, `D1/ 4'16
+PGrocer
+T-54.25
+LGroceries
+^
+D1/ 5'16
+C*
+PDiner
+T-20.00
+LDining
+^
+D1/ 7'16
+Cc
+PCafe
+T-5.00
+LDining
+^
+D1/ 6'16
+CX
+PPaycheck
+T1000.00
+LSalary
+^
+`, "")
+
+	// When they are translated to ledger
+	// Then the cleared transactions are pending and the reconciled
+	// transaction is cleared
+	for _, tc := range []struct {
+		payee  string
+		expect string
+	}{
+		{"Grocer", "2016/01/04   Grocer"},
+		{"Diner", "2016/01/05 !  Diner"},
+		{"Cafe", "2016/01/07 !  Cafe"},
+		{"Paycheck", "2016/01/06 *  Paycheck"},
+	} {
+		if !strings.Contains(output, tc.expect) {
+			t.Errorf("%s: yields %q: expected %q\n", tc.payee, output, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package qif translates qif/reader data back to QIF.
+package qif
+
+import (
+	"fmt"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/account"
+	"github.com/maloquacious/qif/reader/category"
+	"github.com/maloquacious/qif/reader/class"
+	"github.com/maloquacious/qif/reader/security"
+	"github.com/maloquacious/qif/reader/tag"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/stdlib"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type QIF struct {
+	Accounts   []*account.Record
+	Categories []*category.Record
+	Securities []*security.Record
+	Tags       []*tag.Record
+	Classes    []*class.Record
+	Registers  []*Register
+	Memorized  []*transaction.Record
+	Prices     []*transaction.Record
+	Unknown    []*reader.RawSection
+}
+
+// Register is a run of transactions that belong to the same account.
+// Each register is written as an account header followed by a
+// transaction section.
+type Register struct {
+	Account string
+	Type    string
+	Records []*transaction.Record
+}
+
+func Translate(r *reader.Reader) (*QIF, error) {
+	var q QIF
+
+	if r.Accounts != nil {
+		q.Accounts = r.Accounts.Records
+	}
+	if r.Categories != nil {
+		q.Categories = r.Categories.Records
+	}
+	if r.Securities != nil {
+		q.Securities = r.Securities.Records
+	}
+	if r.Tags != nil {
+		q.Tags = r.Tags.Records
+	}
+	if r.Classes != nil {
+		q.Classes = r.Classes.Records
+	}
+	q.Memorized, q.Prices, q.Unknown = r.Memorized, r.Prices, r.Unknown
+
+	for _, a := range q.Accounts {
+		if !a.StatementBalanceDate.IsZero() {
+			if _, err := qdate(a.StatementBalanceDate); err != nil {
+				return nil, fmt.Errorf("%d: account: %w", a.Line, err)
+			}
+		}
+	}
+
+	var register *Register
+	for _, t := range r.Transactions {
+		if register == nil || register.Account != t.Account || register.Type != t.Type {
+			switch t.Type {
+			case "Bank", "Cash", "CCard", "Invst", "Oth A", "Oth L":
+			default:
+				return nil, fmt.Errorf("%d: transaction: %w %q", t.Line, reader.ErrUnsupportedAccountType, t.Type)
+			}
+			register = &Register{Account: t.Account, Type: t.Type}
+			q.Registers = append(q.Registers, register)
+		}
+		register.Records = append(register.Records, t)
+	}
+	if len(q.Registers) != 0 && len(q.Accounts) == 0 {
+		return nil, fmt.Errorf("transactions: missing account list")
+	}
+
+	for _, records := range [][]*transaction.Record{r.Transactions, r.Memorized, r.Prices} {
+		for _, t := range records {
+			if !t.Date.IsZero() {
+				if _, err := qdate(t.Date); err != nil {
+					return nil, fmt.Errorf("%d: transaction: %w", t.Line, err)
+				}
+			}
+		}
+	}
+
+	return &q, nil
+}
+
+func (q *QIF) Write(w io.Writer) error {
+	qw := &qwriter{w: w}
+
+	if len(q.Tags) != 0 {
+		qw.header("!Type:Tag")
+		for _, t := range q.Tags {
+			qw.field("N", t.Name)
+			qw.field("D", t.Description)
+			qw.extra(t.Extra)
+			qw.eor()
+		}
+	}
+
+	if len(q.Classes) != 0 {
+		qw.header("!Type:Class")
+		for _, c := range q.Classes {
+			qw.field("N", c.Name)
+			qw.field("D", c.Description)
+			qw.extra(c.Extra)
+			qw.eor()
+		}
+	}
+
+	if len(q.Categories) != 0 {
+		qw.header("!Type:Cat")
+		for _, c := range q.Categories {
+			qw.field("N", c.Name)
+			qw.field("D", c.Description)
+			if c.IsTaxRelated {
+				qw.header("T")
+			}
+			qw.field("R", c.TaxSchedule)
+			if c.IsIncome {
+				qw.header("I")
+			} else {
+				qw.header("E")
+			}
+			for _, amount := range c.BudgetAmount {
+				qw.header("B" + amount)
+			}
+			qw.extra(c.Extra)
+			qw.eor()
+		}
+	}
+
+	if len(q.Accounts) != 0 {
+		qw.header("!Option:AutoSwitch")
+		qw.header("!Account")
+		for _, a := range q.Accounts {
+			qw.field("N", a.Name)
+			qw.field("T", a.Type)
+			qw.field("D", a.Description)
+			qw.field("L", a.CreditLimit)
+			qw.field("$", a.StatementBalance)
+			qw.date("/", a.StatementBalanceDate)
+			qw.extra(a.Extra)
+			qw.eor()
+		}
+		qw.header("!Clear:AutoSwitch")
+	}
+
+	for _, register := range q.Registers {
+		qw.header("!Account")
+		qw.field("N", register.Account)
+		qw.field("T", register.Type)
+		qw.eor()
+		qw.header("!Type:" + register.Type)
+		for _, t := range register.Records {
+			qw.transaction(t)
+		}
+	}
+
+	if len(q.Securities) != 0 {
+		qw.header("!Type:Security")
+		for _, s := range q.Securities {
+			qw.field("N", s.Name)
+			qw.field("S", s.Ticker)
+			qw.field("T", s.Type)
+			qw.field("G", s.Risk)
+			qw.field("D", s.Description)
+			qw.extra(s.Extra)
+			qw.eor()
+		}
+	}
+
+	if len(q.Memorized) != 0 {
+		qw.header("!Type:Memorized")
+		for _, t := range q.Memorized {
+			qw.transaction(t)
+		}
+	}
+
+	if len(q.Prices) != 0 {
+		qw.header("!Type:Prices")
+		for _, p := range q.Prices {
+			date, err := qdate(p.Date)
+			if err != nil && qw.err == nil {
+				qw.err = err
+			}
+			qw.header(fmt.Sprintf("\"%s\",%s,\"%s\"", p.Ticker, p.Price, date))
+			qw.eor()
+		}
+	}
+
+	for _, raw := range q.Unknown {
+		qw.header(raw.Header)
+		for _, line := range raw.Lines {
+			qw.header(line)
+		}
+	}
+
+	if qw.err != nil {
+		return qw.err
+	}
+
+	var registers int
+	for _, register := range q.Registers {
+		registers += len(register.Records)
+	}
+	fmt.Printf("qif: wrote %8d accounts\n", len(q.Accounts))
+	fmt.Printf("qif: wrote %8d categories\n", len(q.Categories))
+	fmt.Printf("qif: wrote %8d memorized\n", len(q.Memorized))
+	fmt.Printf("qif: wrote %8d prices\n", len(q.Prices))
+	fmt.Printf("qif: wrote %8d securities\n", len(q.Securities))
+	fmt.Printf("qif: wrote %8d tags\n", len(q.Tags))
+	fmt.Printf("qif: wrote %8d classes\n", len(q.Classes))
+	fmt.Printf("qif: wrote %8d transactions\n", registers)
+	if len(q.Unknown) != 0 {
+		fmt.Printf("qif: wrote %8d unknown sections\n", len(q.Unknown))
+	}
+
+	return nil
+}
+
+// qwriter remembers the first error so that the callers don't have to
+// check every line that they write.
+type qwriter struct {
+	w   io.Writer
+	err error
+}
+
+// header writes a line exactly as given.
+func (qw *qwriter) header(s string) {
+	if qw.err != nil {
+		return
+	}
+	_, qw.err = fmt.Fprintf(qw.w, "%s\n", s)
+}
+
+// field writes a field only if it has a value.
+func (qw *qwriter) field(code, value string) {
+	if value != "" {
+		qw.header(code + value)
+	}
+}
+
+// date writes a date field in QIF format.
+func (qw *qwriter) date(code string, value stdlib.CivilDate) {
+	if !value.IsZero() {
+		date, err := qdate(value)
+		if err != nil && qw.err == nil {
+			qw.err = err
+		}
+		qw.header(code + date)
+	}
+}
+
+// extra writes the fields that the reader didn't recognize. The codes are
+// sorted so that the output doesn't depend on the order of the map.
+func (qw *qwriter) extra(fields map[string][]string) {
+	codes := make([]string, 0, len(fields))
+	for code := range fields {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		for _, value := range fields[code] {
+			qw.header(code + value)
+		}
+	}
+}
+
+// eor writes the end of record marker.
+func (qw *qwriter) eor() {
+	qw.header("^")
+}
+
+// transaction writes a transaction or memorized transaction record.
+func (qw *qwriter) transaction(t *transaction.Record) {
+	qw.field("K", t.MemorizedFlag)
+	qw.date("D", t.Date)
+	qw.field("N", t.RefNo)
+	qw.field("Y", t.Ticker)
+	qw.field("I", t.Interest)
+	qw.field("Q", t.Quantity)
+	qw.field("U", t.AmountUCode)
+	qw.field("T", t.AmountTCode)
+	qw.field("O", t.Commission)
+	qw.field("C", t.ClearedStatus)
+	qw.field("P", t.Payee)
+	qw.field("M", t.Memo)
+	for _, line := range t.Address {
+		qw.header("A" + line)
+	}
+	if t.ToAccount != "" {
+		qw.header("L[" + t.ToAccount + "]")
+	}
+	qw.field("L", t.Category)
+	for i, split := range t.Split {
+		// the reader attaches a memo or amount that isn't preceded by a
+		// category to an implied split, so only the first split may be
+		// written without one.
+		if split.Account != "" {
+			qw.header("S[" + split.Account + "]")
+		} else if split.Category != "" || i != 0 || !split.Implied {
+			qw.header("S" + split.Category)
+		}
+		qw.field("E", split.Memo)
+		qw.field("$", split.Amount)
+	}
+	for i, amount := range t.BudgetAmount {
+		if i < 7 && amount != "" {
+			qw.header(strconv.Itoa(i+1) + amount)
+		}
+	}
+	qw.extra(t.Extra)
+	qw.eor()
+}
+
+// qdate translates a date to the format that Quicken uses. The month is
+// not padded and the day is padded with a space. Years in the 21st
+// century are written as two digits after an apostrophe (eg, 2016/09/03
+// is translated to `9/ 3'16`). All other years are written with four
+// digits after a slash (eg, `12/31/1999`), since a two-digit year after a
+// slash depends on the century pivot of whatever reads the file.
+func qdate(date stdlib.CivilDate) (string, error) {
+	if !date.IsValid() || !(1 <= date.Year && date.Year <= 9999) {
+		return "", fmt.Errorf("invalid date %04d/%02d/%02d", date.Year, date.Month, date.Day)
+	}
+	mm, dd, yyyy := int(date.Month), date.Day, date.Year
+	if 2000 <= yyyy && yyyy <= 2099 {
+		return fmt.Sprintf("%d/%2d'%02d", mm, dd, yyyy-2000), nil
+	}
+	return fmt.Sprintf("%d/%2d/%04d", mm, dd, yyyy), nil
+}
@@ -0,0 +1,219 @@
+/*
+ * qif - a package to convert QIF data
+ *
+ * Copyright (c) 2021 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package qif_test
+
+import (
+	"bytes"
+	"github.com/maloquacious/qif/reader"
+	"github.com/maloquacious/qif/reader/transaction"
+	"github.com/maloquacious/qif/scanner"
+	"github.com/maloquacious/qif/stdlib"
+	"github.com/maloquacious/qif/writer/qif"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// Specification: QIF writer
+
+	// Given a QIF file
+	golden, err := ioutil.ReadFile("testdata/golden.qif")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the file is read and written back out
+	// Then the output is identical to the input
+	input := read(t, golden)
+	output := write(t, input)
+	if !bytes.Equal(golden, output) {
+		t.Errorf("output does not match testdata/golden.qif:\n%s", output)
+	}
+
+	// When the output is read back in
+	// Then the records are identical to the records from the input
+	roundTrip := read(t, output)
+	clearPositions(input)
+	clearPositions(roundTrip)
+	if !reflect.DeepEqual(input, roundTrip) {
+		t.Errorf("round trip does not match input")
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	// Specification: QIF writer with passthrough
+
+	// Given a QIF file with unknown fields and an unknown section
+	input := []byte(`!Type:Tag
+NVacation
+Xcolor=blue
+^
+!Type:Foo
+Qbar
+^
+`)
+	sc, err := scanner.New(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When the file is read with passthrough and written back out
+	r, _, err := reader.ReadWithOptions(sc, reader.Options{Passthrough: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := write(t, r)
+
+	// Then the output is identical to the input
+	if !bytes.Equal(input, output) {
+		t.Errorf("passthrough yields %q: expected %q\n", output, input)
+	}
+}
+
+func TestCenturyPivot(t *testing.T) {
+	// Specification: QIF writer dates
+
+	// Given 20th century dates read with a century pivot of 80
+	input := []byte("!Account\nNChecking\nTBank\n^\n!Account\nNChecking\nTBank\n^\n!Type:Bank\nD12/31/85\nT-1.00\n^\nD1/ 2/1975\nT-2.00\n^\n")
+	opts := reader.Options{Dates: stdlib.DateOptions{Format: stdlib.DateUS, CenturyPivot: 80}}
+	sc, err := scanner.New(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := reader.ReadWithOptions(sc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When they are written out
+	output := write(t, r)
+
+	// Then the years have four digits
+	for _, expect := range []string{"D12/31/1985\n", "D1/ 2/1975\n"} {
+		if !bytes.Contains(output, []byte(expect)) {
+			t.Errorf("date yields %q: expected %q\n", output, expect)
+		}
+	}
+
+	// And they are read back the same with the same pivot
+	if sc, err = scanner.New(output); err != nil {
+		t.Fatal(err)
+	}
+	roundTrip, _, err := reader.ReadWithOptions(sc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expect := range []string{"1985-12-31", "1975-01-02"} {
+		if yields := roundTrip.Transactions[i].Date.String(); yields != expect {
+			t.Errorf("date %d yields %q: expected %q\n", i, yields, expect)
+		}
+	}
+}
+
+func TestInvalidPriceDate(t *testing.T) {
+	// Specification: QIF writer price dates
+
+	// Given a price with a date that can't be written
+	q := &qif.QIF{Prices: []*transaction.Record{{Ticker: "ABC", Price: "10.00", Date: stdlib.CivilDate{Year: 10000, Month: 1, Day: 1}}}}
+
+	// When it is written out
+	var buf bytes.Buffer
+	err := q.Write(&buf)
+
+	// Then it returns an error
+	if err == nil {
+		t.Errorf("price date yields %q: expected error\n", buf.String())
+	}
+}
+
+func read(t *testing.T, input []byte) *reader.Reader {
+	t.Helper()
+	sc, err := scanner.New(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := reader.Read(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func write(t *testing.T, r *reader.Reader) []byte {
+	t.Helper()
+	q, err := qif.Translate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := q.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// clearPositions resets the position of every record since they
+// depend on the layout of the file rather than the data.
+func clearPositions(r *reader.Reader) {
+	if r.Accounts != nil {
+		r.Accounts.Line, r.Accounts.Col = 0, 0
+		for _, record := range r.Accounts.Records {
+			record.Line, record.Col, record.Span = 0, 0, scanner.Span{}
+		}
+	}
+	if r.Categories != nil {
+		r.Categories.Line, r.Categories.Col = 0, 0
+		for _, record := range r.Categories.Records {
+			record.Line, record.Col, record.Span = 0, 0, scanner.Span{}
+		}
+	}
+	if r.Classes != nil {
+		r.Classes.Line, r.Classes.Col = 0, 0
+		for _, record := range r.Classes.Records {
+			record.Line, record.Col, record.Span = 0, 0, scanner.Span{}
+		}
+	}
+	if r.Securities != nil {
+		r.Securities.Line, r.Securities.Col = 0, 0
+		for _, record := range r.Securities.Records {
+			record.Line, record.Col, record.Span = 0, 0, scanner.Span{}
+		}
+	}
+	if r.Tags != nil {
+		r.Tags.Line, r.Tags.Col = 0, 0
+		for _, record := range r.Tags.Records {
+			record.Line, record.Col, record.Span = 0, 0, scanner.Span{}
+		}
+	}
+	for _, records := range [][]*transaction.Record{r.Transactions, r.Memorized, r.Prices} {
+		for _, record := range records {
+			record.Line, record.Col, record.Span = 0, 0, scanner.Span{}
+			for _, split := range record.Split {
+				split.Line, split.Col, split.Span = 0, 0, scanner.Span{}
+			}
+		}
+	}
+}